@@ -0,0 +1,173 @@
+package circuit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveOptions configures AdaptiveTripFunc. Zero values use the defaults
+// noted on each field.
+type AdaptiveOptions struct {
+	// Sensitivity is how many standard deviations above its baseline the
+	// error rate or mean latency must be to trip the breaker. Defaults to 3.
+	Sensitivity float64
+
+	// MinSamples is the number of calls the rolling window must hold before
+	// the breaker may trip. Defaults to 20.
+	MinSamples int64
+
+	// Warmup is the number of baseline observations required before the
+	// breaker may trip. Defaults to 10.
+	Warmup int
+
+	// Interval is the minimum time between baseline observations. Defaults
+	// to one second.
+	Interval time.Duration
+
+	// Alpha is the weight of each new observation in the exponentially
+	// weighted baseline. Defaults to 0.1.
+	Alpha float64
+
+	// MinRateDeviation is the smallest standard deviation assumed for the
+	// error rate, so a baseline of no errors does not trip on the first
+	// one. Defaults to 0.05.
+	MinRateDeviation float64
+
+	// MinLatencyDeviation is the smallest standard deviation assumed for
+	// the mean latency, as a fraction of the baseline. Defaults to 0.1.
+	MinLatencyDeviation float64
+}
+
+// adaptiveTripFunc is an AdaptiveTripFunc. Each breaker evaluating it learns
+// its own baseline, kept in the breaker's tripData.
+type adaptiveTripFunc struct {
+	options AdaptiveOptions
+}
+
+// adaptiveBaseline holds exponentially weighted means and variances of the
+// error rate and mean latency.
+type adaptiveBaseline struct {
+	options      AdaptiveOptions
+	rate         ewma
+	latency      ewma
+	observations int
+	lastObserved time.Time
+	lock         sync.Mutex
+}
+
+type ewma struct {
+	mean     float64
+	variance float64
+}
+
+func (e *ewma) observe(x, alpha float64, first bool) {
+	if first {
+		e.mean = x
+		return
+	}
+	diff := x - e.mean
+	e.mean += alpha * diff
+	e.variance = (1 - alpha) * (e.variance + alpha*diff*diff)
+}
+
+// zscore returns how many standard deviations x is above the mean, using
+// minDeviation when the learned deviation is smaller.
+func (e *ewma) zscore(x, minDeviation float64) float64 {
+	deviation := math.Max(math.Sqrt(e.variance), minDeviation)
+	if deviation == 0 {
+		return 0
+	}
+	return (x - e.mean) / deviation
+}
+
+// AdaptiveTripFunc returns a TripFunc that learns a baseline error rate and
+// mean latency for the breaker and trips when either deviates from it by more
+// than Sensitivity standard deviations. This suits services whose normal error
+// rate is not zero, where a fixed threshold needs careful tuning.
+//
+// The baseline is updated from the breaker's rolling window whenever the
+// TripFunc is evaluated, at most once per Interval; observations that are
+// themselves anomalous are not learned. Latency is only considered for calls
+// made with Call or recorded with a duration. Set Options.CheckOnSuccess, as
+// NewAdaptiveBreaker does, so the TripFunc is evaluated after every call:
+// otherwise the baseline is only sampled when calls fail, and a rise in
+// latency without errors does not trip the breaker.
+//
+// Every breaker using the TripFunc learns a baseline of its own, so it may be
+// shared, and breakers created with CloneConfig start from scratch.
+func AdaptiveTripFunc(options AdaptiveOptions) TripFunc {
+	if options.Sensitivity == 0 {
+		options.Sensitivity = 3
+	}
+	if options.MinSamples == 0 {
+		options.MinSamples = 20
+	}
+	if options.Warmup == 0 {
+		options.Warmup = 10
+	}
+	if options.Interval == 0 {
+		options.Interval = time.Second
+	}
+	if options.Alpha == 0 {
+		options.Alpha = 0.1
+	}
+	if options.MinRateDeviation == 0 {
+		options.MinRateDeviation = 0.05
+	}
+	if options.MinLatencyDeviation == 0 {
+		options.MinLatencyDeviation = 0.1
+	}
+
+	f := &adaptiveTripFunc{options: options}
+	return f.shouldTrip
+}
+
+// NewAdaptiveBreaker creates a Breaker with an AdaptiveTripFunc, evaluated
+// after successes as well as failures.
+func NewAdaptiveBreaker(options AdaptiveOptions) *Breaker {
+	return NewBreakerWithOptions(&Options{
+		ShouldTrip:     AdaptiveTripFunc(options),
+		CheckOnSuccess: true,
+	})
+}
+
+func (f *adaptiveTripFunc) shouldTrip(cb *Breaker) bool {
+	samples := cb.Failures() + cb.Successes()
+	if samples < f.options.MinSamples {
+		return false
+	}
+
+	b, ok := cb.tripData.Load(f)
+	if !ok {
+		b, _ = cb.tripData.LoadOrStore(f, &adaptiveBaseline{options: f.options})
+	}
+	return b.(*adaptiveBaseline).shouldTrip(cb)
+}
+
+func (b *adaptiveBaseline) shouldTrip(cb *Breaker) bool {
+
+	rate := cb.ErrorRate()
+	latency := float64(cb.MeanLatency())
+	now := cb.Clock.Now()
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	anomalous := false
+	if b.observations >= b.options.Warmup {
+		minLatencyDeviation := b.latency.mean * b.options.MinLatencyDeviation
+		anomalous = b.rate.zscore(rate, b.options.MinRateDeviation) > b.options.Sensitivity ||
+			(latency > 0 && b.latency.zscore(latency, minLatencyDeviation) > b.options.Sensitivity)
+	}
+
+	if !anomalous && (b.lastObserved.IsZero() || now.Sub(b.lastObserved) >= b.options.Interval) {
+		first := b.observations == 0
+		b.rate.observe(rate, b.options.Alpha, first)
+		b.latency.observe(latency, b.options.Alpha, first)
+		b.observations++
+		b.lastObserved = now
+	}
+
+	return anomalous
+}
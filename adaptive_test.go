@@ -0,0 +1,124 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/facebookgo/clock"
+)
+
+func TestAdaptiveTripFunc(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{
+		Clock:         c,
+		WindowTime:    time.Hour,
+		WindowBuckets: 1,
+		ShouldTrip: AdaptiveTripFunc(AdaptiveOptions{
+			MinSamples: 10,
+			Warmup:     5,
+			Interval:   time.Second,
+		}),
+	})
+
+	// Learn a baseline error rate of 20%.
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 8; j++ {
+			cb.Success()
+		}
+		cb.Fail()
+		cb.Fail()
+		c.Add(time.Second)
+	}
+	if cb.Tripped() {
+		t.Fatal("expected the baseline error rate to not trip the breaker")
+	}
+
+	// A sustained error rate well above the baseline trips it.
+	for i := 0; i < 30 && !cb.Tripped(); i++ {
+		cb.Fail()
+	}
+	if !cb.Tripped() {
+		t.Fatalf("expected an error rate of %.2f to trip the breaker", cb.ErrorRate())
+	}
+}
+
+func TestAdaptiveTripFuncLatency(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{
+		Clock:         c,
+		WindowTime:    time.Hour,
+		WindowBuckets: 1,
+		ShouldTrip: AdaptiveTripFunc(AdaptiveOptions{
+			MinSamples:       10,
+			Warmup:           5,
+			MinRateDeviation: 1,
+		}),
+	})
+
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 9; j++ {
+			cb.SuccessWithDuration(10 * time.Millisecond)
+		}
+		cb.FailWithDuration(10 * time.Millisecond)
+		c.Add(time.Second)
+	}
+	if cb.Tripped() {
+		t.Fatal("expected the baseline latency to not trip the breaker")
+	}
+
+	for i := 0; i < 100 && !cb.Tripped(); i++ {
+		cb.FailWithDuration(time.Second)
+	}
+	if !cb.Tripped() {
+		t.Fatalf("expected a mean latency of %v to trip the breaker", cb.MeanLatency())
+	}
+}
+
+func TestAdaptiveBreakerLatencyWithoutErrors(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewAdaptiveBreaker(AdaptiveOptions{MinSamples: 10, Warmup: 5})
+	cb.Clock = c
+
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			cb.SuccessWithDuration(10 * time.Millisecond)
+		}
+		c.Add(time.Second)
+	}
+	if cb.Tripped() {
+		t.Fatal("expected the baseline latency to not trip the breaker")
+	}
+
+	for i := 0; i < 100 && !cb.Tripped(); i++ {
+		cb.SuccessWithDuration(time.Second)
+	}
+	if !cb.Tripped() {
+		t.Fatalf("expected slow successes with a mean latency of %v to trip the breaker", cb.MeanLatency())
+	}
+}
+
+func TestAdaptiveBreakerClonesLearnSeparately(t *testing.T) {
+	c := clock.NewMock()
+	template := NewBreakerWithOptions(&Options{
+		Clock:          c,
+		ShouldTrip:     AdaptiveTripFunc(AdaptiveOptions{MinSamples: 10, Warmup: 5}),
+		CheckOnSuccess: true,
+	})
+	a, b := template.CloneConfig(), template.CloneConfig()
+
+	// Only a learns a baseline, of no errors.
+	for i := 0; i < 10; i++ {
+		for j := 0; j < 10; j++ {
+			a.Success()
+		}
+		c.Add(time.Second)
+	}
+
+	// b has not warmed up, so it can not trip however high its error rate.
+	for i := 0; i < 20; i++ {
+		b.Fail()
+	}
+	if b.Tripped() {
+		t.Fatal("expected a clone to learn its own baseline")
+	}
+}
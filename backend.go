@@ -0,0 +1,140 @@
+package circuit
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultSyncInterval is the default interval at which a breaker with a
+// Backend polls it for changes made by other processes.
+var DefaultSyncInterval = time.Second
+
+// BackendState is the trip state of a breaker as shared through a Backend.
+type BackendState struct {
+	Tripped bool      `json:"tripped"`
+	Broken  bool      `json:"broken"`
+	Updated time.Time `json:"updated"`
+}
+
+// Backend shares breaker trip state between processes, so that when one
+// instance of a service trips a named breaker the others observe the trip
+// and stop calling the failing dependency too. A breaker with a Backend
+// publishes its state whenever it trips, is broken or resets, and applies
+// states published by others that are newer than the last it knows of.
+//
+// See the circuitredis package for a Redis implementation.
+type Backend interface {
+	// Publish stores the state of the named breaker.
+	Publish(ctx context.Context, name string, state BackendState) error
+
+	// Fetch returns the last state published for the named breaker. ok is
+	// false if none has been published.
+	Fetch(ctx context.Context, name string) (state BackendState, ok bool, err error)
+}
+
+// publish sends the breaker's state to its backend in the background. The
+// state is written by the breaker's sync goroutine, which only writes the
+// latest state, so that a slow write can not overwrite a newer one.
+func (cb *Breaker) publish() {
+	if cb.backend == nil || cb.Name == "" {
+		return
+	}
+
+	cb.pendingLock.Lock()
+	now := cb.Clock.Now()
+	atomic.StoreInt64(&cb.syncedAt, now.UnixNano())
+	cb.pending = &BackendState{
+		Tripped: cb.Tripped(),
+		Broken:  atomic.LoadInt32(&cb.broken) == 1,
+		Updated: now,
+	}
+	cb.pendingLock.Unlock()
+
+	select {
+	case cb.publishes <- struct{}{}:
+	default:
+	}
+}
+
+// sync publishes the breaker's state and polls its backend every
+// SyncInterval until the breaker is closed.
+func (cb *Breaker) sync() {
+	ticker := cb.Clock.Ticker(cb.syncInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-cb.publishes:
+			cb.publishPending()
+		case <-ticker.C:
+			cb.syncOnce()
+		case <-cb.done:
+			return
+		}
+	}
+}
+
+// publishPending writes the state last set by publish, if it has not been
+// written yet.
+func (cb *Breaker) publishPending() {
+	cb.pendingLock.Lock()
+	state := cb.pending
+	cb.pending = nil
+	cb.pendingLock.Unlock()
+	if state == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cb.syncInterval())
+	defer cancel()
+	cb.backend.Publish(ctx, cb.Name, *state)
+}
+
+// syncOnce fetches the breaker's state from its backend and applies it if it
+// is newer than the breaker's own.
+func (cb *Breaker) syncOnce() {
+	name := cb.Name
+	if name == "" {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cb.syncInterval())
+	defer cancel()
+	state, ok, err := cb.backend.Fetch(ctx, name)
+	if err != nil || !ok {
+		return
+	}
+
+	updated := state.Updated.UnixNano()
+	for {
+		synced := atomic.LoadInt64(&cb.syncedAt)
+		if updated <= synced {
+			return
+		}
+		if atomic.CompareAndSwapInt64(&cb.syncedAt, synced, updated) {
+			break
+		}
+	}
+
+	switch {
+	case state.Broken:
+		atomic.StoreInt32(&cb.broken, 1)
+		cb.trip(nil)
+	case state.Tripped:
+		if !cb.Tripped() {
+			cb.trip(nil)
+		}
+	case cb.Tripped():
+		cb.reset()
+	}
+}
+
+func (cb *Breaker) syncInterval() time.Duration {
+	cb.optionsLock.RLock()
+	defer cb.optionsLock.RUnlock()
+	if cb.options.SyncInterval > 0 {
+		return cb.options.SyncInterval
+	}
+	return DefaultSyncInterval
+}
@@ -0,0 +1,104 @@
+package circuit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/clock"
+)
+
+type memoryBackend struct {
+	states map[string]BackendState
+	lock   sync.Mutex
+}
+
+func (b *memoryBackend) Publish(ctx context.Context, name string, state BackendState) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.states[name] = state
+	return nil
+}
+
+func (b *memoryBackend) Fetch(ctx context.Context, name string) (BackendState, bool, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	state, ok := b.states[name]
+	return state, ok, nil
+}
+
+func (b *memoryBackend) waitFor(t *testing.T, name string, tripped bool) {
+	for i := 0; i < 1000; i++ {
+		if state, ok, _ := b.Fetch(context.Background(), name); ok && state.Tripped == tripped {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("expected %s to be published with tripped=%v", name, tripped)
+}
+
+func TestBackend(t *testing.T) {
+	backend := &memoryBackend{states: make(map[string]BackendState)}
+	c := clock.NewMock()
+	newBreaker := func() *Breaker {
+		return NewBreakerWithOptions(&Options{
+			Name:    "db",
+			Clock:   c,
+			Backend: backend,
+		})
+	}
+	a, b := newBreaker(), newBreaker()
+
+	c.Add(time.Millisecond)
+	a.Trip()
+	backend.waitFor(t, "db", true)
+	b.syncOnce()
+	if !b.Tripped() {
+		t.Fatal("expected the remote trip to be applied")
+	}
+
+	c.Add(time.Millisecond)
+	b.Reset()
+	backend.waitFor(t, "db", false)
+	a.syncOnce()
+	if a.Tripped() {
+		t.Fatal("expected the remote reset to be applied")
+	}
+
+	// A state older than the breaker's own is ignored.
+	c.Add(time.Millisecond)
+	a.Trip()
+	backend.Publish(context.Background(), "db", BackendState{Updated: c.Now().Add(-time.Millisecond)})
+	a.syncOnce()
+	if !a.Tripped() {
+		t.Fatal("expected a stale remote state to be ignored")
+	}
+}
+
+// slowTripBackend takes a while to publish trips.
+type slowTripBackend struct {
+	memoryBackend
+}
+
+func (b *slowTripBackend) Publish(ctx context.Context, name string, state BackendState) error {
+	if state.Tripped {
+		time.Sleep(20 * time.Millisecond)
+	}
+	return b.memoryBackend.Publish(ctx, name, state)
+}
+
+func TestBackendPublishesInOrder(t *testing.T) {
+	backend := &slowTripBackend{memoryBackend{states: make(map[string]BackendState)}}
+	cb := NewBreakerWithOptions(&Options{Name: "db", Backend: backend})
+	defer cb.Close()
+
+	cb.Trip()
+	cb.Reset()
+	backend.waitFor(t, "db", false)
+
+	time.Sleep(50 * time.Millisecond)
+	if state, _, _ := backend.Fetch(context.Background(), "db"); state.Tripped {
+		t.Fatal("expected the slow trip not to overwrite the reset")
+	}
+}
@@ -0,0 +1,143 @@
+package circuit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultBrownoutInterval is how often a Brownout re-evaluates its breaker's
+// error rate, which changes as the rolling window advances.
+var DefaultBrownoutInterval = time.Second
+
+// Brownout maps the health of a breaker to a degradation level between 0
+// (healthy) and 100 (fully degraded). Applications can use the level to
+// progressively disable optional features, such as recommendations or
+// avatars, as a dependency degrades instead of waiting for the breaker to
+// trip.
+//
+// The level is 0 while the breaker's error rate is at or below MinErrorRate,
+// rises linearly to 100 at MaxErrorRate, and is 100 while the breaker is
+// tripped. Until the breaker's rolling window holds MinSamples calls, the
+// level is 0 unless the breaker is tripped, so that a few failures on an idle
+// breaker do not disable every feature.
+type Brownout struct {
+	MinErrorRate float64
+	MaxErrorRate float64
+	MinSamples   int64
+
+	breaker     *Breaker
+	events      *Subscription
+	level       int32
+	subscribers []chan int
+	subLock     sync.Mutex
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewBrownout starts tracking the degradation level of cb.
+func NewBrownout(cb *Breaker, minErrorRate, maxErrorRate float64, minSamples int64) *Brownout {
+	b := &Brownout{
+		MinErrorRate: minErrorRate,
+		MaxErrorRate: maxErrorRate,
+		MinSamples:   minSamples,
+		breaker:      cb,
+		events:       cb.NewSubscription(context.Background(), 1), // events only wake the loop up
+		stop:         make(chan struct{}),
+	}
+	b.update()
+
+	ticker := cb.Clock.Ticker(DefaultBrownoutInterval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case _, ok := <-b.events.Events():
+				if !ok {
+					return
+				}
+			case <-ticker.C:
+			case <-b.stop:
+				return
+			}
+			b.update()
+		}
+	}()
+
+	return b
+}
+
+// Level returns the current degradation level, from 0 to 100.
+func (b *Brownout) Level() int {
+	return int(atomic.LoadInt32(&b.level))
+}
+
+// Enabled returns true if a feature that should be disabled at the given
+// degradation level is still enabled, i.e. if the current level is below it.
+// A feature that is the first to go would use a low level such as 10, an
+// important one a high level such as 90.
+func (b *Brownout) Enabled(level int) bool {
+	return b.Level() < level
+}
+
+// Subscribe returns a channel that receives the new level whenever it
+// changes. If the receiver falls behind, the oldest levels are dropped.
+func (b *Brownout) Subscribe() <-chan int {
+	c := make(chan int, 10)
+	b.subLock.Lock()
+	b.subscribers = append(b.subscribers, c)
+	b.subLock.Unlock()
+	return c
+}
+
+// Stop stops tracking the breaker. The level is no longer updated.
+func (b *Brownout) Stop() {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+		b.events.Unsubscribe()
+	})
+}
+
+func (b *Brownout) update() {
+	level := int32(b.computeLevel())
+	if atomic.SwapInt32(&b.level, level) == level {
+		return
+	}
+
+	b.subLock.Lock()
+	defer b.subLock.Unlock()
+	for _, c := range b.subscribers {
+		select {
+		case c <- int(level):
+		default:
+			select {
+			case <-c:
+			default:
+			}
+			select {
+			case c <- int(level):
+			default:
+			}
+		}
+	}
+}
+
+func (b *Brownout) computeLevel() int {
+	if b.breaker.Tripped() {
+		return 100
+	}
+	if b.breaker.Failures()+b.breaker.Successes() < b.MinSamples {
+		return 0
+	}
+
+	rate := b.breaker.ErrorRate()
+	if rate <= b.MinErrorRate {
+		return 0
+	}
+	if rate >= b.MaxErrorRate {
+		return 100
+	}
+	return int(math.Round((rate - b.MinErrorRate) / (b.MaxErrorRate - b.MinErrorRate) * 100))
+}
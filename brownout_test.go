@@ -0,0 +1,78 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBrownout(t *testing.T) {
+	cb := NewBreaker()
+	b := NewBrownout(cb, 0.1, 0.5, 10)
+	defer b.Stop()
+	levels := b.Subscribe()
+
+	if l := b.Level(); l != 0 {
+		t.Fatalf("expected level 0 for a healthy breaker, got %d", l)
+	}
+
+	// An error rate of 0.3 is halfway between 0.1 and 0.5.
+	for i := 0; i < 7; i++ {
+		cb.Success()
+	}
+	for i := 0; i < 3; i++ {
+		cb.Fail()
+	}
+	expectLevel(t, levels, 50)
+
+	if !b.Enabled(60) || b.Enabled(40) {
+		t.Fatal("expected features above the level to be enabled and below it disabled")
+	}
+
+	cb.Trip()
+	expectLevel(t, levels, 100)
+
+	cb.Reset()
+	expectLevel(t, levels, 0)
+}
+
+func expectLevel(t *testing.T, levels <-chan int, expected int) {
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case l := <-levels:
+			if l == expected {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("expected level to reach %d", expected)
+		}
+	}
+}
+
+func TestBrownoutMinSamples(t *testing.T) {
+	cb := NewBreaker()
+	b := NewBrownout(cb, 0.1, 0.5, 10)
+	defer b.Stop()
+
+	cb.Fail()
+	b.update()
+	if l := b.Level(); l != 0 {
+		t.Fatalf("expected level 0 below the minimum samples, got %d", l)
+	}
+
+	cb.Trip()
+	b.update()
+	if l := b.Level(); l != 100 {
+		t.Fatalf("expected level 100 for a tripped breaker, got %d", l)
+	}
+}
+
+func TestBrownoutStop(t *testing.T) {
+	cb := NewBreaker()
+	b := NewBrownout(cb, 0.1, 0.5, 10)
+	b.Stop()
+
+	if n := len(cb.subscribers.load()); n != 0 {
+		t.Fatalf("expected the brownout to unsubscribe from the breaker, got %d subscribers", n)
+	}
+}
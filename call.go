@@ -0,0 +1,180 @@
+package circuit
+
+import (
+	"context"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+// CallOption configures a single call made with CallWithOptions.
+type CallOption func(*callOptions)
+
+type callOptions struct {
+	ctx        context.Context
+	timeout    time.Duration
+	fallback   func(error) error
+	classifier func(error) bool
+	ignored    func(error) bool
+	metadata   map[string]string
+}
+
+// WithContext sets the context of the call. As with CallContext, if ctx is
+// canceled the call's error is not recorded as a failure.
+func WithContext(ctx context.Context) CallOption {
+	return func(o *callOptions) {
+		o.ctx = ctx
+	}
+}
+
+// WithTimeout sets the time out of the call. If the called function takes
+// longer than timeout to run, ErrBreakerTimeout is returned and a failure is
+// recorded.
+func WithTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// WithFallback sets a function that is called with the error whenever the call
+// returns one, including ErrBreakerOpen and ErrBreakerTimeout. Its result is
+// returned in place of the error; returning nil recovers from it.
+func WithFallback(fallback func(err error) error) CallOption {
+	return func(o *callOptions) {
+		o.fallback = fallback
+	}
+}
+
+// WithClassifier sets a function that decides whether an error returned by the
+// called function is a failure. Errors it returns false for are still returned
+// to the caller but are recorded as successes. By default every error is a
+// failure.
+func WithClassifier(isFailure func(err error) bool) CallOption {
+	return func(o *callOptions) {
+		o.classifier = isFailure
+	}
+}
+
+// WithIgnored sets a function that decides whether an error returned by the
+// called function means the call did not reach the protected service, for
+// example because it was refused before being sent. Such errors are returned
+// to the caller without recording a success or a failure, and a half open
+// trial call is given back.
+func WithIgnored(isIgnored func(err error) bool) CallOption {
+	return func(o *callOptions) {
+		o.ignored = isIgnored
+	}
+}
+
+// WithMetadata attaches a key/value pair, such as operation=GetUser, to the
+// call. Metadata is passed to listeners in the ListenerEvents caused by the
+// call, and a Panel uses it to emit failure counts broken down by tag.
+func WithMetadata(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.metadata == nil {
+			o.metadata = make(map[string]string)
+		}
+		o.metadata[key] = value
+	}
+}
+
+// CallWithOptions wraps a function the Breaker will protect, like Call, with
+// per-call behavior configured by opts. Without options it is the same as
+// Call(circuit, 0).
+func (cb *Breaker) CallWithOptions(circuit func() error, opts ...CallOption) error {
+	o := &callOptions{ctx: context.Background()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return cb.call(ignoreContext(circuit), o)
+}
+
+// Go runs circuit asynchronously, protected by the Breaker like Execute, and
+// returns a channel that receives the call's result, nil on success, and is
+// then closed. As with Execute, an open breaker rejects the call with
+// ErrBreakerOpen, a call that takes longer than timeout fails with
+// ErrBreakerTimeout, and failures are recorded. Use it to fan out calls to
+// many dependencies at once.
+func (cb *Breaker) Go(ctx context.Context, circuit func(ctx context.Context) error, timeout time.Duration) <-chan error {
+	errc := make(chan error, 1)
+	go func() {
+		errc <- cb.Execute(ctx, circuit, timeout)
+		close(errc)
+	}()
+	return errc
+}
+
+// DefaultRetryAttempts is the most times CallWithRetry calls a function when
+// its RetryPolicy does not set MaxAttempts.
+var DefaultRetryAttempts = 3
+
+// RetryPolicy configures the retries made by CallWithRetry.
+type RetryPolicy struct {
+	// BackOff gives the delay before each retry. Retrying stops when it
+	// returns backoff.Stop. If nil, retries are made without delay.
+	BackOff backoff.BackOff
+
+	// MaxAttempts is the most times the function is called, including the
+	// first. If 0, DefaultRetryAttempts is used.
+	MaxAttempts int
+}
+
+// CallWithRetry is like CallContext, but retries circuit while it fails, as
+// configured by policy. Each attempt is recorded by the breaker. Retrying
+// stops as soon as an attempt trips the breaker or is rejected by it, for
+// example with ErrBreakerOpen or ErrBreakerTooManyRequests, and when ctx is
+// done; the last attempt's error is returned. policy.BackOff is
+// reset before the first retry, so it must not be shared by concurrent calls.
+func (cb *Breaker) CallWithRetry(ctx context.Context, circuit func() error, timeout time.Duration, policy RetryPolicy) error {
+	b := policy.BackOff
+	if b == nil {
+		b = &backoff.ZeroBackOff{}
+	}
+	b.Reset()
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultRetryAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := cb.CallContext(ctx, circuit, timeout)
+		if err == nil || cb.Tripped() || ctx.Err() != nil || attempt >= maxAttempts {
+			return err
+		}
+		if _, ok := Code(err); ok && !IsTimeout(err) {
+			return err
+		}
+
+		next := b.NextBackOff()
+		if next == backoff.Stop {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-cb.Clock.After(next):
+		}
+	}
+}
+
+// Do calls fn with cb, like Call, and returns its result. If the call does not
+// succeed, for example because the breaker is open or the call timed out, the
+// zero value of T is returned along with the error.
+func Do[T any](cb *Breaker, fn func() (T, error), timeout time.Duration) (T, error) {
+	return DoContext(context.Background(), cb, fn, timeout)
+}
+
+// DoContext is like Do, but with the context handling of CallContext.
+func DoContext[T any](ctx context.Context, cb *Breaker, fn func() (T, error), timeout time.Duration) (T, error) {
+	var result T
+	err := cb.call(func(context.Context) error {
+		var err error
+		result, err = fn()
+		return err
+	}, &callOptions{ctx: ctx, timeout: timeout})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return result, nil
+}
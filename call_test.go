@@ -0,0 +1,311 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/facebookgo/clock"
+)
+
+func TestCallWithOptionsFallback(t *testing.T) {
+	cb := NewThresholdBreaker(1)
+	cb.Trip()
+
+	var fallbackErr error
+	err := cb.CallWithOptions(func() error {
+		return nil
+	}, WithFallback(func(err error) error {
+		fallbackErr = err
+		return nil
+	}))
+
+	if err != nil {
+		t.Fatalf("expected fallback to recover, got %v", err)
+	}
+	if fallbackErr != ErrBreakerOpen {
+		t.Fatalf("expected fallback to receive ErrBreakerOpen, got %v", fallbackErr)
+	}
+}
+
+func TestCallWithOptionsClassifier(t *testing.T) {
+	notFound := errors.New("not found")
+	cb := NewThresholdBreaker(1)
+
+	err := cb.CallWithOptions(func() error {
+		return notFound
+	}, WithClassifier(func(err error) bool {
+		return err != notFound
+	}))
+
+	if err != notFound {
+		t.Fatalf("expected the call's error to be returned, got %v", err)
+	}
+	if cb.Tripped() {
+		t.Fatal("expected breaker to not be tripped")
+	}
+	if s := cb.Successes(); s != 1 {
+		t.Fatalf("expected 1 success, got %d", s)
+	}
+}
+
+func TestCallWithOptionsIgnored(t *testing.T) {
+	skipped := errors.New("not sent")
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{Clock: c, ShouldTrip: ThresholdTripFunc(1)})
+	cb.Fail()
+	c.Add(cb.nextBackOff + 1)
+
+	err := cb.CallWithOptions(func() error {
+		return skipped
+	}, WithIgnored(func(err error) bool {
+		return err == skipped
+	}))
+	if err != skipped {
+		t.Fatalf("expected the call's error to be returned, got %v", err)
+	}
+	if !cb.Tripped() || cb.Successes() != 0 || cb.Failures() != 1 {
+		t.Fatalf("expected no outcome to be recorded, got %d successes and %d failures",
+			cb.Successes(), cb.Failures())
+	}
+
+	if err := cb.Call(func() error { return nil }, 0); err != nil {
+		t.Fatalf("expected the trial call to be given back, got %v", err)
+	}
+	if cb.Tripped() {
+		t.Fatal("expected the next trial call to reset the breaker")
+	}
+}
+
+func TestCallWithOptionsContext(t *testing.T) {
+	cb := NewThresholdBreaker(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	cb.CallWithOptions(func() error {
+		return errors.New("error")
+	}, WithContext(ctx))
+
+	if cb.Tripped() {
+		t.Fatal("expected canceled call to not trip the breaker")
+	}
+}
+
+func TestCallWithOptionsMetadata(t *testing.T) {
+	cb := NewThresholdBreaker(1)
+	events := make(chan ListenerEvent, 10)
+	cb.AddListener(events)
+
+	cb.CallWithOptions(func() error {
+		return errors.New("error")
+	}, WithMetadata("operation", "GetUser"))
+
+	for _, expected := range []BreakerEvent{BreakerFail, BreakerTripped} {
+		e := <-events
+		if e.Event != expected {
+			t.Fatalf("expected event %d, got %d", expected, e.Event)
+		}
+		if op := e.Metadata["operation"]; op != "GetUser" {
+			t.Fatalf("expected operation metadata on event %d, got %q", e.Event, op)
+		}
+	}
+}
+
+func TestGo(t *testing.T) {
+	cb := NewThresholdBreaker(2)
+	ctx := context.Background()
+
+	ok := cb.Go(ctx, func(context.Context) error { return nil }, 0)
+	failed := cb.Go(ctx, func(context.Context) error { return errors.New("failed") }, 0)
+	if err := <-ok; err != nil {
+		t.Fatalf("expected a successful call, got %v", err)
+	}
+	if err := <-failed; err == nil || err.Error() != "failed" {
+		t.Fatalf("expected the call's error, got %v", err)
+	}
+	if _, open := <-ok; open {
+		t.Fatal("expected the result channel to be closed")
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	slow := cb.Go(ctx, func(ctx context.Context) error {
+		select {
+		case <-release:
+		case <-ctx.Done():
+		}
+		return nil
+	}, time.Millisecond)
+	if err := <-slow; err != ErrBreakerTimeout {
+		t.Fatalf("expected a time out, got %v", err)
+	}
+	if n := cb.Timeouts(); n != 1 {
+		t.Fatalf("expected the time out to be recorded, got %d timeouts", n)
+	}
+
+	if !cb.Tripped() {
+		t.Fatal("expected the breaker to trip after 2 failures")
+	}
+	if err := <-cb.Go(ctx, func(context.Context) error { return nil }, 0); err != ErrBreakerOpen {
+		t.Fatalf("expected the open breaker to reject the call, got %v", err)
+	}
+}
+
+func TestCallWithRetry(t *testing.T) {
+	cb := NewThresholdBreaker(10)
+	calls := 0
+	err := cb.CallWithRetry(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return errors.New("transient")
+		}
+		return nil
+	}, 0, RetryPolicy{BackOff: backoff.NewConstantBackOff(time.Millisecond), MaxAttempts: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls != 3 || cb.Failures() != 2 || cb.Successes() != 1 {
+		t.Fatalf("expected 3 attempts to be recorded, got %d calls, %d failures and %d successes",
+			calls, cb.Failures(), cb.Successes())
+	}
+
+	cb.Reset()
+	calls = 0
+	fail := errors.New("down")
+	err = cb.CallWithRetry(context.Background(), func() error {
+		calls++
+		return fail
+	}, 0, RetryPolicy{MaxAttempts: 4})
+	if err != fail || calls != 4 {
+		t.Fatalf("expected 4 attempts and the last error, got %d attempts and %v", calls, err)
+	}
+}
+
+func TestCallWithRetryStopsWhenTripped(t *testing.T) {
+	cb := NewThresholdBreaker(2)
+	calls := 0
+	fail := errors.New("down")
+	err := cb.CallWithRetry(context.Background(), func() error {
+		calls++
+		return fail
+	}, 0, RetryPolicy{MaxAttempts: 10})
+	if err != fail {
+		t.Fatalf("expected the tripping attempt's error, got %v", err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected retries to stop when the breaker tripped, got %d attempts", calls)
+	}
+
+	err = cb.CallWithRetry(context.Background(), func() error {
+		calls++
+		return nil
+	}, 0, RetryPolicy{MaxAttempts: 10})
+	if err != ErrBreakerOpen || calls != 2 {
+		t.Fatalf("expected the open breaker to reject the call without retries, got %v", err)
+	}
+}
+
+func TestCallWithRetryDefaultAttempts(t *testing.T) {
+	cb := NewThresholdBreaker(10)
+	calls := 0
+	err := cb.CallWithRetry(context.Background(), func() error {
+		calls++
+		return errors.New("down")
+	}, 0, RetryPolicy{})
+	if err == nil || calls != DefaultRetryAttempts {
+		t.Fatalf("expected %d attempts, got %d attempts and %v", DefaultRetryAttempts, calls, err)
+	}
+}
+
+// countingBackOff retries without delay and counts the retries.
+type countingBackOff struct {
+	retries int
+}
+
+func (b *countingBackOff) NextBackOff() time.Duration {
+	b.retries++
+	return 0
+}
+
+func (b *countingBackOff) Reset() {}
+
+func TestCallWithRetryStopsWhenRejected(t *testing.T) {
+	cb := NewBreakerWithOptions(&Options{MaxConcurrent: 1})
+	finish := make(chan struct{})
+	defer close(finish)
+	started := make(chan struct{})
+	go cb.Call(func() error {
+		close(started)
+		<-finish
+		return nil
+	}, 0)
+	<-started
+
+	b := &countingBackOff{}
+	err := cb.CallWithRetry(context.Background(), func() error {
+		return nil
+	}, 0, RetryPolicy{BackOff: b, MaxAttempts: 10})
+	if err != ErrBreakerTooManyRequests || b.retries != 0 {
+		t.Fatalf("expected the rejected call not to be retried, got %d retries and %v", b.retries, err)
+	}
+}
+
+func TestCallWithRetryContext(t *testing.T) {
+	cb := NewThresholdBreaker(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	err := cb.CallWithRetry(ctx, func() error {
+		calls++
+		cancel()
+		return errors.New("failed")
+	}, 0, RetryPolicy{BackOff: backoff.NewConstantBackOff(time.Hour)})
+	if err == nil || calls != 1 {
+		t.Fatalf("expected canceling the context to stop retrying, got %d attempts and %v", calls, err)
+	}
+}
+
+func TestDo(t *testing.T) {
+	cb := NewThresholdBreaker(1)
+
+	n, err := Do(cb, func() (int, error) {
+		return 42, nil
+	}, 0)
+	if err != nil || n != 42 {
+		t.Fatalf("expected 42, nil, got %d, %v", n, err)
+	}
+
+	s, err := Do(cb, func() (string, error) {
+		return "partial", errors.New("failed")
+	}, 0)
+	if err == nil || s != "" {
+		t.Fatalf("expected the zero value and an error, got %q, %v", s, err)
+	}
+	if !cb.Tripped() {
+		t.Fatal("expected the failure to trip the breaker")
+	}
+
+	_, err = Do(cb, func() (int, error) {
+		return 1, nil
+	}, 0)
+	if err != ErrBreakerOpen {
+		t.Fatalf("expected ErrBreakerOpen, got %v", err)
+	}
+}
+
+func TestDoContextCanceled(t *testing.T) {
+	cb := NewThresholdBreaker(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := DoContext(ctx, cb, func() (int, error) {
+		return 0, ctx.Err()
+	}, 0)
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if cb.Tripped() {
+		t.Fatal("expected a canceled call to not trip the breaker")
+	}
+}
@@ -15,11 +15,9 @@
 // threshold. It does not matter how long it takes to reach the threshold, but the
 // failures do need to be consecutive.
 //
-//
 // When wrapping blocks of code with a Breaker's Call() function, a time out can be
 // specified. If the time out is reached, the breaker's Fail() function will be called.
 //
-//
 // Other types of circuit breakers can be easily built by creating a Breaker and
 // adding a custom TripFunc. A TripFunc is called when a Breaker Fail()s and receives
 // the breaker as an argument. It then returns true or false to indicate whether the
@@ -27,12 +25,12 @@
 //
 // The package also provides a wrapper around an http.Client that wraps all of
 // the http.Client functions with a Breaker.
-//
 package circuit
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"log/slog"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -56,22 +54,77 @@ const (
 
 	// BreakerReady is sent when the breaker enters the half open state and is ready to retry
 	BreakerReady BreakerEvent = iota
+
+	// BreakerTimeout is sent when a call fails by timing out, just before
+	// the BreakerFail event for the failure
+	BreakerTimeout BreakerEvent = iota
 )
 
 // ListenerEvent includes a reference to the circuit breaker and the event.
 type ListenerEvent struct {
 	CB    *Breaker
 	Event BreakerEvent
+
+	// Name is the breaker's Name. Events from a Panel's NewSubscription are
+	// named by the name the breaker was added to the panel as.
+	Name string
+
+	// From and To are the breaker's states before and after the event.
+	// They are the same for events that do not change the state, such as a
+	// failure that does not trip the breaker.
+	From State
+	To   State
+
+	// Err is the error of the call that caused the event, if any.
+	Err error
+
+	// Time is when the event happened, by the breaker's Clock.
+	Time time.Time
+
+	// Metadata is the metadata of the call that caused the event, if any.
+	// See WithMetadata.
+	Metadata map[string]string
+
+	opened bool // a BreakerTripped event that tripped a closed breaker
+}
+
+// cause is the call that caused an event.
+type cause struct {
+	err      error
+	metadata map[string]string
 }
 
-type state int
+// State is the state of a Breaker: closed and operational, open and
+// rejecting calls, or half open and allowing trial calls.
+type State int
 
+// States of a Breaker.
 const (
-	open     state = iota
-	halfopen state = iota
-	closed   state = iota
+	StateOpen     State = iota
+	StateHalfOpen State = iota
+	StateClosed   State = iota
 )
 
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateHalfOpen:
+		return "half-open"
+	case StateClosed:
+		return "closed"
+	}
+	return "unknown"
+}
+
+// MarshalText encodes the state as its String, such as "half-open".
+func (s State) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// noDuration is passed for outcomes recorded without a call duration.
+const noDuration time.Duration = -1
+
 var (
 	defaultInitialBackOffInterval = 500 * time.Millisecond
 	defaultBackoffMaxElapsedTime  = 0 * time.Second
@@ -79,8 +132,12 @@ var (
 
 // Error codes returned by Call
 var (
-	ErrBreakerOpen    = errors.New("breaker open")
-	ErrBreakerTimeout = errors.New("breaker time out")
+	ErrBreakerOpen    error = &Error{Code: CodeOpen, Message: "breaker open"}
+	ErrBreakerTimeout error = &Error{Code: CodeTimeout, Message: "breaker time out"}
+
+	// ErrBreakerTooManyRequests is returned when a breaker with MaxConcurrent
+	// set already has that many calls in flight and its queue is full.
+	ErrBreakerTooManyRequests error = &Error{Code: CodeConcurrency, Message: "breaker too many requests"}
 )
 
 // TripFunc is a function called by a Breaker's Fail() function and determines whether
@@ -88,9 +145,35 @@ var (
 // boolean. By default, a Breaker has no TripFunc.
 type TripFunc func(*Breaker) bool
 
+// CircuitBreaker is the interface implemented by Breaker. Applications can depend
+// on it rather than on *Breaker so that breakers can be replaced or mocked.
+type CircuitBreaker interface {
+	Call(circuit func() error, timeout time.Duration) error
+	CallContext(ctx context.Context, circuit func() error, timeout time.Duration) error
+	Fail()
+	Success()
+	Trip()
+	Reset()
+	Break()
+	Ready() bool
+	Tripped() bool
+	Failures() int64
+	ConsecFailures() int64
+	Successes() int64
+	ErrorRate() float64
+}
+
+var _ CircuitBreaker = (*Breaker)(nil)
+
 // Breaker is the base of a circuit breaker. It maintains failure and success counters
 // as well as the event subscribers.
 type Breaker struct {
+	// Name identifies the breaker in its String() output, its log records and
+	// its Backend. Set it before using the breaker and do not change it
+	// afterwards. Breakers created by a Panel's GetOrCreate or by a
+	// BreakerSet are named after their name there.
+	Name string
+
 	// BackOff is the backoff policy that is used when determining if the breaker should
 	// attempt to retry. A breaker created with NewBreaker will use an exponential backoff
 	// policy by default.
@@ -107,23 +190,107 @@ type Breaker struct {
 	_              [4]byte // pad to fix golang issue #599
 	consecFailures int64
 	lastFailure    int64 // stored as nanoseconds since the Unix epoch
-	halfOpens      int64
+	trippedAt      int64 // like lastFailure, zero when not tripped
+	trips          int64
+	halfOpens      int64 // probes granted in the current half open round
+	halfOpenOKs    int64 // successful probes in the current round
+	probing        bool
+	lastRetry      int64 // start of the current round, like lastFailure
 	counts         *window
+	errors         *errorHistory
+	options        Options
+	group          *Breaker
+	slots          chan struct{}
+	flapper        *Flapper
+	backend        Backend
+	logger         *slog.Logger
+	syncedAt       int64         // time of the last state published or applied
+	pending        *BackendState // the state to publish next, see publish
+	pendingLock    sync.Mutex
+	publishes      chan struct{} // wakes sync up to publish pending
+	queued         int64
 	nextBackOff    time.Duration
 	tripped        int32
 	broken         int32
-	eventReceivers []chan BreakerEvent
-	listeners      []chan ListenerEvent
+	eventState     int32 // the State reported by the last event
+	subscribers    subscriberList
+	done           chan struct{}
+	closeOnce      sync.Once
 	backoffLock    sync.Mutex
+	optionsLock    sync.RWMutex // guards options and updates to ShouldTrip
+	tripData       sync.Map     // state TripFuncs keep per breaker, such as adaptive baselines
 }
 
 // Options holds breaker configuration options.
 type Options struct {
+	Name          string
 	BackOff       backoff.BackOff
 	Clock         clock.Clock
 	ShouldTrip    TripFunc
 	WindowTime    time.Duration
 	WindowBuckets int
+
+	// ErrorHistory is the number of call errors the breaker remembers, see
+	// Errors. It defaults to DefaultErrorHistory; a negative value disables
+	// the history.
+	ErrorHistory int
+
+	// CaptureStacks enables capturing the caller's stack trace alongside
+	// recorded errors, at most once per StackInterval, which defaults to
+	// DefaultStackInterval.
+	CaptureStacks bool
+	StackInterval time.Duration
+
+	// Schedule varies the breaker's TripFunc by time of day. During one of
+	// its windows the window's TripFunc is used; at other times ShouldTrip
+	// is. See ScheduledTripFunc.
+	Schedule []ScheduleWindow
+
+	// MaxConcurrent limits the number of calls the breaker runs at once. A
+	// call that times out keeps its place until the function returns. Calls
+	// beyond the limit wait in a queue of up to MaxQueue calls, until a place
+	// frees up or their context is done; calls beyond that are rejected with
+	// ErrBreakerTooManyRequests. Rejected calls are not failures. Zero means
+	// no limit.
+	MaxConcurrent int
+	MaxQueue      int
+
+	// HalfOpenMaxCalls is the number of trial calls allowed at once while
+	// the breaker is half open. HalfOpenSuccessesToClose is the number of
+	// consecutive successful trial calls needed to reset the breaker; a
+	// failed trial call opens it again until the next retry. Both default
+	// to 1.
+	HalfOpenMaxCalls         int
+	HalfOpenSuccessesToClose int
+
+	// Backend shares the breaker's trip state with other processes, see
+	// Backend. The breaker is identified in the backend by its Name, so
+	// it must be set here. The breaker polls the backend every
+	// SyncInterval, which defaults to DefaultSyncInterval.
+	Backend      Backend
+	SyncInterval time.Duration
+
+	// CheckOnSuccess makes the breaker call ShouldTrip after successes as
+	// well as failures, for TripFuncs such as LatencyTripFunc that can trip
+	// the breaker without any failures.
+	CheckOnSuccess bool
+
+	// FlapThreshold enables flap detection, see Flapper and Flapping. The
+	// breaker is flapping when at least this fraction of its last
+	// FlapSamples outcomes (DefaultFlapSamples if zero) changed its state.
+	// While it is flapping, the time before each retry is multiplied by
+	// FlapBackOffMultiplier, if set, to give the dependency time to
+	// recover properly.
+	FlapThreshold         float64
+	FlapSamples           int
+	FlapBackOffMultiplier float64
+
+	// Logger, if set, is used to log the breaker's state changes, its trips
+	// with the error that caused them, the outcomes of its half-open trial
+	// calls, and events dropped because a subscriber fell behind. Trips and
+	// failed trial calls are logged at Warn, other state changes at Info,
+	// and other failures and dropped events at Debug.
+	Logger *slog.Logger
 }
 
 // NewBreakerWithOptions creates a base breaker with a specified backoff, clock and TripFunc
@@ -131,6 +298,7 @@ func NewBreakerWithOptions(options *Options) *Breaker {
 	if options == nil {
 		options = &Options{}
 	}
+	configured := *options
 
 	if options.Clock == nil {
 		options.Clock = clock.New()
@@ -153,13 +321,156 @@ func NewBreakerWithOptions(options *Options) *Breaker {
 		options.WindowBuckets = DefaultWindowBuckets
 	}
 
-	return &Breaker{
+	if options.ErrorHistory == 0 {
+		options.ErrorHistory = DefaultErrorHistory
+	} else if options.ErrorHistory < 0 {
+		options.ErrorHistory = 0
+	}
+
+	if options.StackInterval == 0 {
+		options.StackInterval = DefaultStackInterval
+	}
+
+	shouldTrip := options.ShouldTrip
+	if len(options.Schedule) > 0 {
+		shouldTrip = ScheduledTripFunc(shouldTrip, options.Schedule...)
+	}
+
+	var slots chan struct{}
+	if options.MaxConcurrent > 0 {
+		slots = make(chan struct{}, options.MaxConcurrent)
+	}
+
+	cb := &Breaker{
+		Name:        options.Name,
 		BackOff:     options.BackOff,
 		Clock:       options.Clock,
-		ShouldTrip:  options.ShouldTrip,
+		ShouldTrip:  shouldTrip,
 		nextBackOff: options.BackOff.NextBackOff(),
-		counts:      newWindow(options.WindowTime, options.WindowBuckets),
+		errors:      newErrorHistory(options.ErrorHistory, options.CaptureStacks, options.StackInterval),
+		options:     configured,
+		slots:       slots,
+		backend:     options.Backend,
+		logger:      options.Logger,
+		done:        make(chan struct{}),
+		eventState:  int32(StateClosed),
+	}
+	cb.counts = newWindow(options.WindowTime, options.WindowBuckets, func() time.Time {
+		return cb.Clock.Now()
+	})
+
+	if options.FlapThreshold > 0 {
+		if options.FlapSamples == 0 {
+			options.FlapSamples = DefaultFlapSamples
+		}
+		cb.flapper = NewFlapper(options.FlapSamples, options.FlapThreshold)
+	}
+
+	if options.Backend != nil {
+		cb.publishes = make(chan struct{}, 1)
+		go cb.sync()
 	}
+
+	return cb
+}
+
+// CloneConfig creates a new breaker with the same configuration as cb but none
+// of its state: counters, history, subscribers and trip state all start fresh.
+// The clone has no Name. Use it to create many breakers, such as one per host
+// or tenant, from a tuned template.
+//
+// An exponential backoff policy is copied so that the clone backs off
+// independently; any other policy is shared with the template.
+func (cb *Breaker) CloneConfig() *Breaker {
+	cb.optionsLock.RLock()
+	options := cb.options
+	options.ShouldTrip = cb.ShouldTrip
+	cb.optionsLock.RUnlock()
+
+	options.Name = ""
+	options.Clock = cb.Clock
+	options.Schedule = nil // already part of cb.ShouldTrip
+	if options.BackOff != nil {
+		cb.backoffLock.Lock()
+		options.BackOff = cloneBackOff(cb.BackOff)
+		cb.backoffLock.Unlock()
+	}
+	return NewBreakerWithOptions(&options)
+}
+
+// UpdateOptions changes the breaker's configuration while it is in use, for
+// example from a feature flag system, keeping its counters, trip state and
+// subscribers. Zero values in options keep the current setting. The following
+// options may be changed: ShouldTrip, Schedule, BackOff, WindowTime,
+// WindowBuckets, MaxQueue, HalfOpenMaxCalls and HalfOpenSuccessesToClose.
+// CheckOnSuccess goes with the TripFunc: it is changed, even to false,
+// whenever ShouldTrip is.
+//
+// Changing the window keeps the totals of its counts, but not their spread
+// over time: they are all moved into the newest bucket. A new BackOff takes
+// effect from the breaker's next retry.
+func (cb *Breaker) UpdateOptions(options Options) {
+	cb.optionsLock.Lock()
+	if options.ShouldTrip != nil || len(options.Schedule) > 0 {
+		if options.ShouldTrip != nil {
+			cb.options.ShouldTrip = options.ShouldTrip
+			cb.options.CheckOnSuccess = options.CheckOnSuccess
+		}
+		if len(options.Schedule) > 0 {
+			cb.options.Schedule = options.Schedule
+		}
+		shouldTrip := cb.options.ShouldTrip
+		if len(cb.options.Schedule) > 0 {
+			shouldTrip = ScheduledTripFunc(shouldTrip, cb.options.Schedule...)
+		}
+		cb.ShouldTrip = shouldTrip
+	}
+	if options.WindowTime != 0 {
+		cb.options.WindowTime = options.WindowTime
+	}
+	if options.WindowBuckets != 0 {
+		cb.options.WindowBuckets = options.WindowBuckets
+	}
+	if options.MaxQueue != 0 {
+		cb.options.MaxQueue = options.MaxQueue
+	}
+	if options.HalfOpenMaxCalls != 0 {
+		cb.options.HalfOpenMaxCalls = options.HalfOpenMaxCalls
+	}
+	if options.HalfOpenSuccessesToClose != 0 {
+		cb.options.HalfOpenSuccessesToClose = options.HalfOpenSuccessesToClose
+	}
+	if options.BackOff != nil {
+		cb.options.BackOff = options.BackOff
+	}
+	windowTime, windowBuckets := cb.options.WindowTime, cb.options.WindowBuckets
+	cb.optionsLock.Unlock()
+
+	if options.WindowTime != 0 || options.WindowBuckets != 0 {
+		if windowTime == 0 {
+			windowTime = DefaultWindowTime
+		}
+		if windowBuckets == 0 {
+			windowBuckets = DefaultWindowBuckets
+		}
+		cb.counts.Resize(windowTime, windowBuckets)
+	}
+
+	if options.BackOff != nil {
+		cb.backoffLock.Lock()
+		cb.BackOff = options.BackOff
+		cb.BackOff.Reset()
+		cb.backoffLock.Unlock()
+	}
+}
+
+func cloneBackOff(b backoff.BackOff) backoff.BackOff {
+	if eb, ok := b.(*backoff.ExponentialBackOff); ok {
+		clone := *eb
+		clone.Reset()
+		return &clone
+	}
+	return b
 }
 
 // NewBreaker creates a base breaker with an exponential backoff and no TripFunc
@@ -181,69 +492,119 @@ func NewConsecutiveBreaker(threshold int64) *Breaker {
 	})
 }
 
-// NewRateBreaker creates a Breaker with a RateTripFunc.
+// NewRateBreaker creates a Breaker with a RateTripFunc. The error rate is
+// calculated over a window of DefaultWindowTime; use NewWindowedRateBreaker to
+// choose the window.
 func NewRateBreaker(rate float64, minSamples int64) *Breaker {
 	return NewBreakerWithOptions(&Options{
 		ShouldTrip: RateTripFunc(rate, minSamples),
 	})
 }
 
+// NewWindowedRateBreaker creates a Breaker with a RateTripFunc whose error rate
+// and sample count cover only the last windowTime, divided into windowBuckets
+// buckets, so that old outcomes stop counting once they leave the window. Zero
+// values use DefaultWindowTime and DefaultWindowBuckets.
+func NewWindowedRateBreaker(rate float64, minSamples int64, windowTime time.Duration, windowBuckets int) *Breaker {
+	return NewBreakerWithOptions(&Options{
+		ShouldTrip:    RateTripFunc(rate, minSamples),
+		WindowTime:    windowTime,
+		WindowBuckets: windowBuckets,
+	})
+}
+
+// NewLatencyBreaker creates a Breaker that trips when the q-quantile (e.g. 0.99)
+// of call durations exceeds threshold, once at least minSamples calls have been
+// timed. This catches dependencies that slow down without failing. Durations
+// are recorded by Call and CallContext, and by SuccessWithDuration and
+// FailWithDuration.
+func NewLatencyBreaker(q float64, threshold time.Duration, minSamples int64) *Breaker {
+	return NewBreakerWithOptions(&Options{
+		ShouldTrip:     LatencyTripFunc(q, threshold, minSamples),
+		CheckOnSuccess: true,
+	})
+}
+
 // Subscribe returns a channel of BreakerEvents. Whenever the breaker changes state,
 // the state will be sent over the channel. See BreakerEvent for the types of events.
+// The channel is buffered; if it fills up, the oldest event is dropped. Use
+// NewSubscription for a subscription that can be canceled.
 func (cb *Breaker) Subscribe() <-chan BreakerEvent {
-	eventReader := make(chan BreakerEvent)
-	output := make(chan BreakerEvent, 100)
-
-	go func() {
-		for v := range eventReader {
-			select {
-			case output <- v:
-			default:
-				<-output
-				output <- v
-			}
-		}
-	}()
-	cb.eventReceivers = append(cb.eventReceivers, eventReader)
-	return output
+	s := &eventSubscriber{events: make(chan BreakerEvent, DefaultSubscriptionBuffer)}
+	cb.addSubscriber(s)
+	return s.events
 }
 
 // AddListener adds a channel of ListenerEvents on behalf of a listener.
-// The listener channel must be buffered.
+// The listener channel must be buffered. If it fills up, the oldest event is
+// dropped.
 func (cb *Breaker) AddListener(listener chan ListenerEvent) {
-	cb.listeners = append(cb.listeners, listener)
+	cb.addSubscriber(listenerSubscriber(listener))
 }
 
 // RemoveListener removes a channel previously added via AddListener.
 // Once removed, the channel will no longer receive ListenerEvents.
 // Returns true if the listener was found and removed.
 func (cb *Breaker) RemoveListener(listener chan ListenerEvent) bool {
-	for i, receiver := range cb.listeners {
-		if listener == receiver {
-			cb.listeners = append(cb.listeners[:i], cb.listeners[i+1:]...)
-			return true
-		}
+	return cb.removeSubscriber(listenerSubscriber(listener))
+}
+
+// Close releases the breaker's resources. It stops syncing with the breaker's
+// Backend and ends its subscriptions, closing the channels returned by
+// Subscribe and NewSubscription. Listeners added with AddListener are removed,
+// but their channels are left open. The breaker can still be called after it
+// is closed, but it no longer delivers events to anyone subscribed before.
+func (cb *Breaker) Close() {
+	cb.closeOnce.Do(func() {
+		close(cb.done)
+	})
+
+	for _, s := range cb.subscribers.clear() {
+		s.close()
 	}
-	return false
 }
 
 // Trip will trip the circuit breaker. After Trip() is called, Tripped() will
 // return true.
 func (cb *Breaker) Trip() {
-	atomic.StoreInt32(&cb.tripped, 1)
+	if cb.trip(nil) {
+		cb.publish()
+	}
+}
+
+// trip trips the breaker, returning true if it was not already tripped. c is
+// the call that caused the trip, if any.
+func (cb *Breaker) trip(c *cause) bool {
 	now := cb.Clock.Now()
+	changed := atomic.SwapInt32(&cb.tripped, 1) == 0
+	if changed {
+		atomic.StoreInt64(&cb.trippedAt, now.UnixNano())
+		atomic.AddInt64(&cb.trips, 1)
+	}
 	atomic.StoreInt64(&cb.lastFailure, now.UnixNano())
-	cb.sendEvent(BreakerTripped)
+	cb.emit(BreakerTripped, c, changed)
+	return changed
 }
 
 // Reset will reset the circuit breaker. After Reset() is called, Tripped() will
 // return false.
 func (cb *Breaker) Reset() {
+	if cb.reset() {
+		cb.publish()
+	}
+}
+
+// reset resets the breaker, returning true if it was tripped.
+func (cb *Breaker) reset() bool {
 	atomic.StoreInt32(&cb.broken, 0)
-	atomic.StoreInt32(&cb.tripped, 0)
-	atomic.StoreInt64(&cb.halfOpens, 0)
+	changed := atomic.SwapInt32(&cb.tripped, 0) == 1
+	atomic.StoreInt64(&cb.trippedAt, 0)
+	cb.backoffLock.Lock()
+	cb.endProbing()
+	cb.backoffLock.Unlock()
 	cb.ResetCounters()
-	cb.sendEvent(BreakerReset)
+	cb.sendEvent(BreakerReset, nil)
+	return changed
 }
 
 // ResetCounters will reset only the failures, consecFailures, and success counters
@@ -257,24 +618,54 @@ func (cb *Breaker) Tripped() bool {
 	return atomic.LoadInt32(&cb.tripped) == 1
 }
 
+// TrippedAt returns the time the breaker was tripped, or the zero time if it
+// is not tripped.
+func (cb *Breaker) TrippedAt() time.Time {
+	at := atomic.LoadInt64(&cb.trippedAt)
+	if at == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, at)
+}
+
+// Flapping returns true if flap detection is enabled with
+// Options.FlapThreshold and the breaker is flapping.
+func (cb *Breaker) Flapping() bool {
+	return cb.flapper != nil && cb.flapper.Flapping()
+}
+
+// Trips returns the number of times the breaker has tripped.
+func (cb *Breaker) Trips() int64 {
+	return atomic.LoadInt64(&cb.trips)
+}
+
 // Break trips the circuit breaker and prevents it from auto resetting. Use this when
 // manual control over the circuit breaker state is needed.
 func (cb *Breaker) Break() {
 	atomic.StoreInt32(&cb.broken, 1)
-	cb.Trip()
+	cb.trip(nil)
+	cb.publish()
 }
 
-// Failures returns the number of failures for this circuit breaker.
+// Failures returns the number of failures recorded in the breaker's rolling
+// window.
 func (cb *Breaker) Failures() int64 {
 	return cb.counts.Failures()
 }
 
+// Timeouts returns the number of failures recorded in the breaker's rolling
+// window that were calls timing out. They are also counted by Failures.
+func (cb *Breaker) Timeouts() int64 {
+	return cb.counts.Timeouts()
+}
+
 // ConsecFailures returns the number of consecutive failures that have occured.
 func (cb *Breaker) ConsecFailures() int64 {
 	return atomic.LoadInt64(&cb.consecFailures)
 }
 
-// Successes returns the number of successes for this circuit breaker.
+// Successes returns the number of successes recorded in the breaker's rolling
+// window.
 func (cb *Breaker) Successes() int64 {
 	return cb.counts.Successes()
 }
@@ -283,48 +674,154 @@ func (cb *Breaker) Successes() int64 {
 // increment the failure counters and store the time of the last failure. If the
 // breaker has a TripFunc it will be called, tripping the breaker if necessary.
 func (cb *Breaker) Fail() {
-	cb.counts.Fail()
+	cb.fail(noDuration, nil)
+}
+
+// FailWithDuration is like Fail but also records d, the duration of the failed
+// call, in the breaker's latency statistics. Use it when driving the breaker
+// manually rather than with Call.
+func (cb *Breaker) FailWithDuration(d time.Duration) {
+	cb.fail(d, nil)
+}
+
+// fail records a failure. c is the call that failed, if any.
+func (cb *Breaker) fail(d time.Duration, c *cause) {
+	timedOut := c != nil && IsTimeout(c.err)
+	switch {
+	case timedOut:
+		cb.counts.TimeoutWithDuration(d)
+	case d == noDuration:
+		cb.counts.Fail()
+	default:
+		cb.counts.FailWithDuration(d)
+	}
 	atomic.AddInt64(&cb.consecFailures, 1)
 	now := cb.Clock.Now()
 	atomic.StoreInt64(&cb.lastFailure, now.UnixNano())
-	cb.sendEvent(BreakerFail)
-	if cb.ShouldTrip != nil && cb.ShouldTrip(cb) {
-		cb.Trip()
+	cb.backoffLock.Lock()
+	cb.endProbing()
+	cb.backoffLock.Unlock()
+	if timedOut {
+		cb.sendEvent(BreakerTimeout, c)
+	}
+	cb.sendEvent(BreakerFail, c)
+	if shouldTrip := cb.tripFunc(); shouldTrip != nil && shouldTrip(cb) {
+		if cb.trip(c) {
+			cb.publish()
+		}
+	}
+	cb.recordFlap()
+	if cb.group != nil {
+		cb.group.fail(d, c)
 	}
 }
 
 // Success is used to indicate a success condition the Breaker should record. If
-// the success was triggered by a retry attempt, the breaker will be Reset().
+// the success was triggered by a retry attempt, and enough retry attempts have
+// succeeded (see Options.HalfOpenSuccessesToClose), the breaker will be Reset().
 func (cb *Breaker) Success() {
+	cb.success(noDuration)
+}
+
+// SuccessWithDuration is like Success but also records d, the duration of the
+// successful call, in the breaker's latency statistics. Use it when driving
+// the breaker manually rather than with Call.
+func (cb *Breaker) SuccessWithDuration(d time.Duration) {
+	cb.success(d)
+}
+
+func (cb *Breaker) success(d time.Duration) {
+	reset := false
+	var probeOKs int64
 	cb.backoffLock.Lock()
-	cb.BackOff.Reset()
-	cb.nextBackOff = cb.BackOff.NextBackOff()
+	if cb.Tripped() && cb.probing {
+		cb.halfOpenOKs++
+		if cb.halfOpenOKs >= cb.halfOpenSuccessesToClose() {
+			reset = true
+		} else {
+			cb.halfOpens--
+			probeOKs = cb.halfOpenOKs
+		}
+	}
+	if !cb.Tripped() || reset {
+		cb.BackOff.Reset()
+		cb.nextBackOff = cb.BackOff.NextBackOff()
+	}
 	cb.backoffLock.Unlock()
 
-	state := cb.state()
-	if state == halfopen {
+	if reset {
 		cb.Reset()
+	} else if probeOKs > 0 {
+		cb.logProbeSuccess(probeOKs)
 	}
 	atomic.StoreInt64(&cb.consecFailures, 0)
-	cb.counts.Success()
+	if d == noDuration {
+		cb.counts.Success()
+	} else {
+		cb.counts.SuccessWithDuration(d)
+	}
+	if !cb.Tripped() {
+		if shouldTrip := cb.successTripFunc(); shouldTrip != nil && shouldTrip(cb) && cb.trip(nil) {
+			cb.publish()
+		}
+	}
+	cb.recordFlap()
+	if cb.group != nil {
+		cb.group.success(d)
+	}
 }
 
 // ErrorRate returns the current error rate of the Breaker, expressed as a floating
-// point number (e.g. 0.9 for 90%), since the last time the breaker was Reset.
+// point number (e.g. 0.9 for 90%), over the breaker's rolling window.
 func (cb *Breaker) ErrorRate() float64 {
 	return cb.counts.ErrorRate()
 }
 
+// MeanLatency returns the mean duration of the calls recorded in the breaker's
+// rolling window. Calls made with Call, and outcomes recorded with
+// SuccessWithDuration or FailWithDuration, are included.
+func (cb *Breaker) MeanLatency() time.Duration {
+	return cb.counts.MeanLatency()
+}
+
+// LatencyQuantile estimates the q-quantile (e.g. 0.99 for the 99th percentile)
+// of the durations of the calls recorded in the breaker's rolling window, like
+// MeanLatency. The estimate is within about 10% of the true value.
+func (cb *Breaker) LatencyQuantile(q float64) time.Duration {
+	d, _ := cb.counts.LatencyQuantile(q)
+	return d
+}
+
+// Errors returns the most recent errors returned by calls that were recorded as
+// failures, oldest first. See Options.ErrorHistory and Options.CaptureStacks.
+func (cb *Breaker) Errors() []ErrorRecord {
+	return cb.errors.Records()
+}
+
 // Ready will return true if the circuit breaker is ready to call the function.
 // It will be ready if the breaker is in a reset state, or if it is time to retry
-// the call for auto resetting.
+// the call for auto resetting. A member of a Group is only ready if its group
+// is ready as well.
 func (cb *Breaker) Ready() bool {
+	ready, _ := cb.ready()
+	return ready
+}
+
+// ready implements Ready, also reporting whether the call it allows is a half
+// open trial call.
+func (cb *Breaker) ready() (ready, trial bool) {
 	state := cb.state()
-	if state == halfopen {
-		atomic.StoreInt64(&cb.halfOpens, 0)
-		cb.sendEvent(BreakerReady)
+	ready = state == StateClosed || state == StateHalfOpen
+	if ready && cb.group != nil && !cb.group.Ready() {
+		if state == StateHalfOpen {
+			cb.releaseTrialCall()
+		}
+		return false, false
 	}
-	return state == closed || state == halfopen
+	if state == StateHalfOpen {
+		cb.sendEvent(BreakerReady, nil)
+	}
+	return ready, state == StateHalfOpen
 }
 
 // Call wraps a function the Breaker will protect. A failure is recorded
@@ -337,18 +834,61 @@ func (cb *Breaker) Call(circuit func() error, timeout time.Duration) error {
 // CallContext is same as Call but if the ctx is canceled after the circuit returned an error,
 // the error will not be marked as a failure because the call was canceled intentionally.
 func (cb *Breaker) CallContext(ctx context.Context, circuit func() error, timeout time.Duration) error {
+	return cb.call(ignoreContext(circuit), &callOptions{ctx: ctx, timeout: timeout})
+}
+
+// Execute is like CallContext, but circuit is passed a context derived from
+// ctx that is canceled when the call times out or returns. A circuit that
+// honors its context therefore stops work that the breaker has already
+// given up on, instead of running on in the background.
+func (cb *Breaker) Execute(ctx context.Context, circuit func(ctx context.Context) error, timeout time.Duration) error {
+	return cb.call(circuit, &callOptions{ctx: ctx, timeout: timeout})
+}
+
+func ignoreContext(circuit func() error) func(context.Context) error {
+	return func(context.Context) error {
+		return circuit()
+	}
+}
+
+// call runs circuit with the given options. It implements Call, CallContext,
+// Execute and CallWithOptions.
+func (cb *Breaker) call(circuit func(context.Context) error, o *callOptions) error {
+	err := cb.protect(circuit, o)
+	if err != nil && o.fallback != nil {
+		return o.fallback(err)
+	}
+	return err
+}
+
+func (cb *Breaker) protect(circuit func(context.Context) error, o *callOptions) error {
 	var err error
+	ctx, timeout := o.ctx, o.timeout
+
+	if err := cb.acquire(ctx); err != nil {
+		return err
+	}
 
-	if !cb.Ready() {
+	ready, trial := cb.ready()
+	if !ready {
+		cb.release()
 		return ErrBreakerOpen
 	}
 
+	callCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	start := cb.Clock.Now()
 	if timeout == 0 {
-		err = circuit()
+		err = func() error {
+			defer cb.release()
+			return circuit(callCtx)
+		}()
 	} else {
 		c := make(chan error, 1)
 		go func() {
-			c <- circuit()
+			defer cb.release()
+			c <- circuit(callCtx)
 			close(c)
 		}()
 
@@ -360,57 +900,298 @@ func (cb *Breaker) CallContext(ctx context.Context, circuit func() error, timeou
 		}
 	}
 
-	if err != nil {
+	if err != nil && o.ignored != nil && o.ignored(err) {
+		if trial {
+			cb.releaseTrialCall()
+		}
+		return err
+	}
+
+	d := cb.Clock.Now().Sub(start)
+	if err != nil && (o.classifier == nil || o.classifier(err)) {
 		if ctx.Err() != context.Canceled {
-			cb.Fail()
+			cb.errors.Record(err, cb.Clock.Now())
+			cb.fail(d, &cause{err: err, metadata: o.metadata})
 		}
 		return err
 	}
 
-	cb.Success()
-	return nil
+	cb.success(d)
+	return err
+}
+
+// acquire takes one of the breaker's MaxConcurrent places, waiting in the
+// queue if there is room in it.
+func (cb *Breaker) acquire(ctx context.Context) error {
+	if cb.slots == nil {
+		return nil
+	}
+
+	select {
+	case cb.slots <- struct{}{}:
+		return nil
+	default:
+	}
+
+	cb.optionsLock.RLock()
+	maxQueue := int64(cb.options.MaxQueue)
+	cb.optionsLock.RUnlock()
+
+	if atomic.AddInt64(&cb.queued, 1) > maxQueue {
+		atomic.AddInt64(&cb.queued, -1)
+		return ErrBreakerTooManyRequests
+	}
+	defer atomic.AddInt64(&cb.queued, -1)
+
+	select {
+	case cb.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release gives back a place taken by acquire.
+func (cb *Breaker) release() {
+	if cb.slots != nil {
+		<-cb.slots
+	}
 }
 
 // state returns the state of the TrippableBreaker. The states available are:
 // closed - the circuit is in a reset state and is operational
 // open - the circuit is in a tripped state
 // halfopen - the circuit is in a tripped state but the reset timeout has passed
-func (cb *Breaker) state() state {
+//
+// Once the reset timeout has passed, a round of trial calls begins and state
+// returns halfopen for up to HalfOpenMaxCalls callers at a time.
+func (cb *Breaker) state() State {
 	tripped := cb.Tripped()
 	if tripped {
 		if atomic.LoadInt32(&cb.broken) == 1 {
-			return open
+			return StateOpen
 		}
 
-		last := atomic.LoadInt64(&cb.lastFailure)
-		since := cb.Clock.Now().Sub(time.Unix(0, last))
-
 		cb.backoffLock.Lock()
 		defer cb.backoffLock.Unlock()
 
-		if cb.nextBackOff != backoff.Stop && since > cb.nextBackOff {
-			if atomic.CompareAndSwapInt64(&cb.halfOpens, 0, 1) {
-				cb.nextBackOff = cb.BackOff.NextBackOff()
-				return halfopen
+		since := cb.sinceLastAttempt()
+
+		if cb.nextBackOff != backoff.Stop && since > cb.retryDelay() {
+			cb.nextBackOff = cb.BackOff.NextBackOff()
+			cb.lastRetry = cb.Clock.Now().UnixNano()
+			cb.probing = true
+			cb.halfOpens = 1
+			cb.halfOpenOKs = 0
+			return StateHalfOpen
+		}
+		if cb.probing && cb.halfOpens < cb.halfOpenMaxCalls() {
+			cb.halfOpens++
+			return StateHalfOpen
+		}
+		return StateOpen
+	}
+	return StateClosed
+}
+
+// sinceLastAttempt returns the time since the last failure or the start of the
+// last round of trial calls, whichever is later. The next round begins once it
+// exceeds nextBackOff. It must be called with backoffLock held.
+func (cb *Breaker) sinceLastAttempt() time.Duration {
+	last := atomic.LoadInt64(&cb.lastFailure)
+	if cb.lastRetry > last {
+		last = cb.lastRetry
+	}
+	return cb.Clock.Now().Sub(time.Unix(0, last))
+}
+
+// retryDelay returns the time to wait after the last attempt before the next
+// retry: nextBackOff, extended while the breaker is flapping. It must be
+// called with backoffLock held.
+func (cb *Breaker) retryDelay() time.Duration {
+	if cb.flapper != nil && cb.options.FlapBackOffMultiplier > 0 && cb.flapper.Flapping() {
+		return time.Duration(float64(cb.nextBackOff) * cb.options.FlapBackOffMultiplier)
+	}
+	return cb.nextBackOff
+}
+
+// recordFlap samples the breaker's state for flap detection.
+func (cb *Breaker) recordFlap() {
+	if cb.flapper != nil {
+		cb.flapper.Record(cb.Tripped())
+	}
+}
+
+// tripFunc returns the breaker's ShouldTrip.
+func (cb *Breaker) tripFunc() TripFunc {
+	cb.optionsLock.RLock()
+	defer cb.optionsLock.RUnlock()
+	return cb.ShouldTrip
+}
+
+// successTripFunc returns the breaker's ShouldTrip if it is to be called after
+// successes, see Options.CheckOnSuccess.
+func (cb *Breaker) successTripFunc() TripFunc {
+	cb.optionsLock.RLock()
+	defer cb.optionsLock.RUnlock()
+	if !cb.options.CheckOnSuccess {
+		return nil
+	}
+	return cb.ShouldTrip
+}
+
+// releaseTrialCall gives back a half open trial call claimed by state() that
+// will not be made.
+func (cb *Breaker) releaseTrialCall() {
+	cb.backoffLock.Lock()
+	defer cb.backoffLock.Unlock()
+	if cb.probing && cb.halfOpens > 0 {
+		cb.halfOpens--
+	}
+}
+
+// endProbing ends the current round of trial calls. It must be called with
+// backoffLock held.
+func (cb *Breaker) endProbing() {
+	cb.probing = false
+	cb.halfOpens = 0
+	cb.halfOpenOKs = 0
+}
+
+func (cb *Breaker) halfOpenMaxCalls() int64 {
+	cb.optionsLock.RLock()
+	defer cb.optionsLock.RUnlock()
+	if cb.options.HalfOpenMaxCalls > 0 {
+		return int64(cb.options.HalfOpenMaxCalls)
+	}
+	return 1
+}
+
+func (cb *Breaker) halfOpenSuccessesToClose() int64 {
+	cb.optionsLock.RLock()
+	defer cb.optionsLock.RUnlock()
+	if cb.options.HalfOpenSuccessesToClose > 0 {
+		return int64(cb.options.HalfOpenSuccessesToClose)
+	}
+	return 1
+}
+
+// String returns a summary of the breaker for use in log statements, such as
+// "payments[open fails=12 rate=0.43 retry-in=8s]".
+func (cb *Breaker) String() string {
+	name := cb.Name
+	if name == "" {
+		name = "breaker"
+	}
+
+	state := cb.State()
+	s := fmt.Sprintf("%s[%s fails=%d rate=%.2f", name, state, cb.Failures(), cb.ErrorRate())
+	if state == StateOpen {
+		if d, ok := cb.retryIn(); ok {
+			if d >= time.Second {
+				d = d.Round(time.Second)
+			} else {
+				d = d.Round(time.Millisecond)
 			}
-			return open
+			s += fmt.Sprintf(" retry-in=%s", d)
 		}
-		return open
 	}
-	return closed
+	return s + "]"
 }
 
-func (cb *Breaker) sendEvent(event BreakerEvent) {
-	for _, receiver := range cb.eventReceivers {
-		receiver <- event
+// retryIn returns how long it will be until a tripped breaker allows a retry.
+// ok is false if the breaker has been broken or will never retry.
+func (cb *Breaker) retryIn() (d time.Duration, ok bool) {
+	if atomic.LoadInt32(&cb.broken) == 1 {
+		return 0, false
 	}
-	for _, listener := range cb.listeners {
-		le := ListenerEvent{CB: cb, Event: event}
-		select {
-		case listener <- le:
-		default:
-			<-listener
-			listener <- le
+
+	cb.backoffLock.Lock()
+	defer cb.backoffLock.Unlock()
+
+	since := cb.sinceLastAttempt()
+
+	if cb.nextBackOff == backoff.Stop {
+		return 0, false
+	}
+	if d = cb.retryDelay() - since; d < 0 {
+		d = 0
+	}
+	return d, true
+}
+
+// State returns the current state of the breaker. Unlike Ready, it does not
+// claim a half open trial call.
+func (cb *Breaker) State() State {
+	if !cb.Tripped() {
+		return StateClosed
+	}
+	if atomic.LoadInt32(&cb.broken) == 1 {
+		return StateOpen
+	}
+
+	cb.backoffLock.Lock()
+	defer cb.backoffLock.Unlock()
+
+	since := cb.sinceLastAttempt()
+
+	if cb.nextBackOff != backoff.Stop && since > cb.retryDelay() {
+		return StateHalfOpen
+	}
+	if cb.probing && cb.halfOpens < cb.halfOpenMaxCalls() {
+		return StateHalfOpen
+	}
+	return StateOpen
+}
+
+// sendEvent delivers event to the breaker's subscribers. c is the call that
+// caused it, if any.
+func (cb *Breaker) sendEvent(event BreakerEvent, c *cause) {
+	cb.emit(event, c, false)
+}
+
+// emit is like sendEvent. For a BreakerTripped event, opened is true if the
+// trip opened a breaker that was not tripped before.
+func (cb *Breaker) emit(event BreakerEvent, c *cause, opened bool) {
+	var to State
+	switch event {
+	case BreakerTripped:
+		to = StateOpen
+	case BreakerReset:
+		to = StateClosed
+	case BreakerReady:
+		to = StateHalfOpen
+	default:
+		// A failure while half open ends the trial, opening the breaker.
+		to = StateClosed
+		if cb.Tripped() {
+			to = StateOpen
+		}
+	}
+	from := State(atomic.SwapInt32(&cb.eventState, int32(to)))
+	cb.logEvent(event, from, to, c)
+
+	subscribers := cb.subscribers.load()
+	if len(subscribers) == 0 {
+		return
+	}
+	le := ListenerEvent{
+		CB:    cb,
+		Event: event,
+		Name:  cb.Name,
+		From:  from,
+		To:    to,
+		Time:  cb.Clock.Now(),
+
+		opened: opened,
+	}
+	if c != nil {
+		le.Err = c.err
+		le.Metadata = c.metadata
+	}
+	for _, s := range subscribers {
+		if s.deliver(le) {
+			cb.logDropped(le)
 		}
 	}
 }
@@ -431,16 +1212,37 @@ func ConsecutiveTripFunc(threshold int64) TripFunc {
 	}
 }
 
+// TimeoutTripFunc returns a TripFunc that trips whenever the number of calls
+// that timed out in the breaker's rolling window reaches threshold. Other
+// failures do not count towards it.
+func TimeoutTripFunc(threshold int64) TripFunc {
+	return func(cb *Breaker) bool {
+		return cb.Timeouts() >= threshold
+	}
+}
+
 // RateTripFunc returns a TripFunc that trips whenever the
 // error rate hits the threshold. The error rate is calculated as such:
 // f = number of failures
 // s = number of successes
 // e = f / (f + s)
-// The error rate is calculated over a sliding window of 10 seconds (by default)
-// This TripFunc will not trip until there have been at least minSamples events.
+// The error rate is calculated over the breaker's rolling window, see
+// Options.WindowTime and Options.WindowBuckets. This TripFunc will not trip
+// until there have been at least minSamples events in the window.
 func RateTripFunc(rate float64, minSamples int64) TripFunc {
 	return func(cb *Breaker) bool {
 		samples := cb.Failures() + cb.Successes()
 		return samples >= minSamples && cb.ErrorRate() >= rate
 	}
 }
+
+// LatencyTripFunc returns a TripFunc that trips whenever the q-quantile of
+// call durations in the rolling window exceeds threshold, once at least
+// minSamples durations have been recorded. Use it with Options.CheckOnSuccess
+// so that slow but successful calls can trip the breaker.
+func LatencyTripFunc(q float64, threshold time.Duration, minSamples int64) TripFunc {
+	return func(cb *Breaker) bool {
+		d, samples := cb.counts.LatencyQuantile(q)
+		return samples >= minSamples && d > threshold
+	}
+}
@@ -2,6 +2,7 @@ package circuit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync/atomic"
 	"testing"
@@ -360,6 +361,55 @@ func TestTimeoutBreaker(t *testing.T) {
 	}
 }
 
+func TestTimeoutTripFunc(t *testing.T) {
+	cb := NewBreakerWithOptions(&Options{ShouldTrip: TimeoutTripFunc(2)})
+	events := cb.Subscribe()
+
+	for i := 0; i < 5; i++ {
+		cb.Call(func() error { return errors.New("failed") }, 0)
+	}
+	if cb.Tripped() {
+		t.Fatal("expected ordinary failures not to trip the breaker")
+	}
+	if n := cb.Timeouts(); n != 0 {
+		t.Fatalf("expected 0 timeouts, got %d", n)
+	}
+
+	release := make(chan struct{})
+	defer close(release)
+	err := cb.Call(func() error {
+		<-release
+		return nil
+	}, time.Millisecond)
+	if err != ErrBreakerTimeout {
+		t.Fatalf("expected a time out, got %v", err)
+	}
+	if cb.Tripped() {
+		t.Fatal("expected the breaker not to trip after 1 timeout")
+	}
+
+	cb.Call(func() error { return ErrBreakerTimeout }, 0)
+	if !cb.Tripped() {
+		t.Fatal("expected the breaker to trip after 2 timeouts")
+	}
+	if n, f := cb.Timeouts(), cb.Failures(); n != 2 || f != 7 {
+		t.Fatalf("expected 2 timeouts of 7 failures, got %d of %d", n, f)
+	}
+
+	var timeouts int
+	for i := 0; i < 8; i++ {
+		if e := <-events; e == BreakerTimeout {
+			timeouts++
+			if next := <-events; next != BreakerFail {
+				t.Fatalf("expected a fail event after the timeout event, got %d", next)
+			}
+		}
+	}
+	if timeouts != 2 {
+		t.Fatalf("expected 2 timeout events, got %d", timeouts)
+	}
+}
+
 func TestRateBreakerTripping(t *testing.T) {
 	cb := NewRateBreaker(0.5, 4)
 	cb.Success()
@@ -385,6 +435,31 @@ func TestRateBreakerSampleSize(t *testing.T) {
 	}
 }
 
+func TestWindowedRateBreaker(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewWindowedRateBreaker(0.5, 4, time.Second, 10)
+	cb.Clock = c
+
+	cb.Fail()
+	cb.Fail()
+	cb.Fail()
+	c.Add(time.Second)
+
+	cb.Success()
+	cb.Success()
+	cb.Success()
+	cb.Fail()
+	if cb.Tripped() {
+		t.Fatalf("expected failures outside the window not to count, error rate is %f", cb.ErrorRate())
+	}
+
+	cb.Fail()
+	cb.Fail()
+	if !cb.Tripped() {
+		t.Fatalf("expected failures within the window to trip, error rate is %f", cb.ErrorRate())
+	}
+}
+
 func TestRateBreakerResets(t *testing.T) {
 	serviceError := fmt.Errorf("service error")
 
@@ -474,3 +549,351 @@ func TestPartialSecondBackoff(t *testing.T) {
 		t.Fatalf("expected breaker to be ready after more than nextBackoff time had passed")
 	}
 }
+
+func TestBreakerString(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewThresholdBreaker(2)
+	cb.Clock = c
+
+	if s := cb.String(); s != "breaker[closed fails=0 rate=0.00]" {
+		t.Fatalf("unexpected string for new breaker: %s", s)
+	}
+
+	cb.Name = "payments"
+	cb.Success()
+	cb.Fail()
+	cb.Fail()
+	cb.nextBackOff = 8 * time.Second
+	if s := cb.String(); s != "payments[open fails=2 rate=0.67 retry-in=8s]" {
+		t.Fatalf("unexpected string for tripped breaker: %s", s)
+	}
+
+	c.Add(9 * time.Second)
+	if s := cb.String(); s != "payments[half-open fails=2 rate=0.67]" {
+		t.Fatalf("unexpected string for half open breaker: %s", s)
+	}
+}
+
+func TestBreakerLatency(t *testing.T) {
+	cb := NewBreaker()
+	cb.SuccessWithDuration(10 * time.Millisecond)
+	cb.FailWithDuration(30 * time.Millisecond)
+
+	if l := cb.MeanLatency(); l != 20*time.Millisecond {
+		t.Fatalf("expected mean latency to be 20ms, got %v", l)
+	}
+	if f := cb.Failures(); f != 1 {
+		t.Fatalf("expected 1 failure, got %d", f)
+	}
+
+	c := clock.NewMock()
+	cb = NewBreaker()
+	cb.Clock = c
+	cb.Call(func() error {
+		c.Add(50 * time.Millisecond)
+		return nil
+	}, 0)
+
+	if l := cb.MeanLatency(); l != 50*time.Millisecond {
+		t.Fatalf("expected Call to record 50ms latency, got %v", l)
+	}
+}
+
+func TestCloneConfig(t *testing.T) {
+	c := clock.NewMock()
+	template := NewBreakerWithOptions(&Options{
+		Name:       "template",
+		Clock:      c,
+		ShouldTrip: ThresholdTripFunc(2),
+	})
+	template.Fail()
+	template.Fail()
+
+	clone := template.CloneConfig()
+	if clone.Tripped() || clone.Failures() != 0 {
+		t.Fatal("expected clone to start with zeroed state")
+	}
+	if clone.Name != "" {
+		t.Fatalf("expected clone to have no name, got %q", clone.Name)
+	}
+	if clone.Clock != c {
+		t.Fatal("expected clone to share the template's clock")
+	}
+	if clone.BackOff == template.BackOff {
+		t.Fatal("expected clone to have its own backoff")
+	}
+
+	clone.Fail()
+	clone.Fail()
+	if !clone.Tripped() {
+		t.Fatal("expected clone to trip like the template")
+	}
+}
+
+func TestMaxConcurrent(t *testing.T) {
+	cb := NewBreakerWithOptions(&Options{
+		ShouldTrip:    ThresholdTripFunc(1),
+		MaxConcurrent: 1,
+		MaxQueue:      1,
+	})
+
+	started := make(chan struct{})
+	finish := make(chan struct{})
+	go cb.Call(func() error {
+		close(started)
+		<-finish
+		return nil
+	}, 0)
+	<-started
+
+	queued := make(chan error)
+	go func() {
+		queued <- cb.Call(func() error { return nil }, 0)
+	}()
+	for atomic.LoadInt64(&cb.queued) != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	err := cb.Call(func() error { return nil }, 0)
+	if err != ErrBreakerTooManyRequests {
+		t.Fatalf("expected ErrBreakerTooManyRequests, got %v", err)
+	}
+	if cb.Failures() != 0 || cb.Tripped() {
+		t.Fatal("expected a rejected call to not be counted as a failure")
+	}
+
+	close(finish)
+	if err := <-queued; err != nil {
+		t.Fatalf("expected the queued call to run, got %v", err)
+	}
+}
+
+func TestMaxConcurrentQueueContext(t *testing.T) {
+	cb := NewBreakerWithOptions(&Options{MaxConcurrent: 1, MaxQueue: 1})
+
+	finish := make(chan struct{})
+	defer close(finish)
+	started := make(chan struct{})
+	go cb.Call(func() error {
+		close(started)
+		<-finish
+		return nil
+	}, 0)
+	<-started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := cb.CallContext(ctx, func() error { return nil }, 0)
+	if err != context.DeadlineExceeded {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestHalfOpenMaxCalls(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{
+		Clock:                    c,
+		HalfOpenMaxCalls:         2,
+		HalfOpenSuccessesToClose: 3,
+	})
+
+	cb.Trip()
+	c.Add(cb.nextBackOff + 1)
+	if !cb.Ready() || !cb.Ready() {
+		t.Fatal("expected two trial calls to be allowed")
+	}
+	if cb.Ready() {
+		t.Fatal("expected a third concurrent trial call to be rejected")
+	}
+
+	cb.Success()
+	if !cb.Tripped() {
+		t.Fatal("expected breaker to stay tripped after one successful trial call")
+	}
+	if !cb.Ready() {
+		t.Fatal("expected a successful trial call to free its place")
+	}
+
+	cb.Success()
+	cb.Success()
+	if cb.Tripped() {
+		t.Fatal("expected breaker to reset after three successful trial calls")
+	}
+}
+
+func TestHalfOpenFailureReopens(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{
+		Clock:                    c,
+		HalfOpenMaxCalls:         2,
+		HalfOpenSuccessesToClose: 2,
+	})
+
+	cb.Trip()
+	c.Add(cb.nextBackOff + 1)
+	cb.Ready()
+	cb.Ready()
+	cb.Success()
+	cb.Fail()
+
+	if !cb.Tripped() {
+		t.Fatal("expected breaker to stay tripped")
+	}
+	if cb.Ready() {
+		t.Fatal("expected a failed trial call to open the breaker until the next retry")
+	}
+
+	c.Add(cb.nextBackOff + 1)
+	cb.Ready()
+	cb.Success()
+	if !cb.Tripped() {
+		t.Fatal("expected successes to be counted from the start of the new round")
+	}
+	cb.Success()
+	if cb.Tripped() {
+		t.Fatal("expected breaker to reset")
+	}
+}
+
+func TestTrips(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreaker()
+	cb.Clock = c
+
+	if !cb.TrippedAt().IsZero() {
+		t.Fatal("expected no trip time for a closed breaker")
+	}
+
+	c.Add(time.Second)
+	cb.Trip()
+	cb.Trip()
+	if cb.Trips() != 1 {
+		t.Fatalf("expected tripping a tripped breaker to not count, got %d trips", cb.Trips())
+	}
+	if at := cb.TrippedAt(); !at.Equal(c.Now()) {
+		t.Fatalf("expected trip time %v, got %v", c.Now(), at)
+	}
+	if cb.State() != StateOpen {
+		t.Fatalf("expected open state, got %s", cb.State())
+	}
+
+	cb.Reset()
+	cb.Trip()
+	if cb.Trips() != 2 {
+		t.Fatalf("expected 2 trips, got %d", cb.Trips())
+	}
+}
+
+func TestLatencyBreaker(t *testing.T) {
+	cb := NewLatencyBreaker(0.9, 100*time.Millisecond, 10)
+
+	for i := 0; i < 10; i++ {
+		cb.SuccessWithDuration(10 * time.Millisecond)
+	}
+	if cb.Tripped() {
+		t.Fatal("expected fast calls to not trip the breaker")
+	}
+
+	for i := 0; i < 5 && !cb.Tripped(); i++ {
+		cb.SuccessWithDuration(time.Second)
+	}
+	if !cb.Tripped() {
+		t.Fatalf("expected slow calls to trip the breaker, p90 is %v", cb.LatencyQuantile(0.9))
+	}
+}
+
+func TestExecuteCancelsOnTimeout(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewThresholdBreaker(1)
+	cb.Clock = c
+
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	errc := make(chan error)
+	go func() {
+		errc <- cb.Execute(context.Background(), func(ctx context.Context) error {
+			close(started)
+			<-ctx.Done()
+			close(stopped)
+			return ctx.Err()
+		}, time.Millisecond)
+	}()
+
+	<-started
+	c.Add(time.Millisecond * 2)
+	if err := <-errc; err != ErrBreakerTimeout {
+		t.Fatalf("expected ErrBreakerTimeout, got %v", err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("expected the circuit's context to be canceled")
+	}
+	if !cb.Tripped() {
+		t.Fatal("expected the time out to trip the breaker")
+	}
+}
+
+func TestExecuteCanceledByCaller(t *testing.T) {
+	cb := NewThresholdBreaker(1)
+	ctx, cancel := context.WithCancel(context.Background())
+
+	err := cb.Execute(ctx, func(ctx context.Context) error {
+		cancel()
+		<-ctx.Done()
+		return ctx.Err()
+	}, 0)
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if cb.Failures() != 0 {
+		t.Fatal("expected a call canceled by the caller to not be a failure")
+	}
+}
+
+func TestUpdateOptions(t *testing.T) {
+	cb := NewThresholdBreaker(2)
+	cb.Success()
+	cb.Fail()
+
+	cb.UpdateOptions(Options{
+		ShouldTrip:    ThresholdTripFunc(3),
+		WindowTime:    time.Minute,
+		WindowBuckets: 4,
+	})
+	if cb.Failures() != 1 || cb.Successes() != 1 {
+		t.Fatalf("expected counts to be kept, got %d failures and %d successes", cb.Failures(), cb.Successes())
+	}
+
+	cb.Fail()
+	if cb.Tripped() {
+		t.Fatal("expected the new threshold to be used")
+	}
+	cb.Fail()
+	if !cb.Tripped() {
+		t.Fatal("expected breaker to trip at the new threshold")
+	}
+}
+
+func TestUpdateOptionsBackOff(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{Clock: c})
+	cb.UpdateOptions(Options{BackOff: &backoff.ConstantBackOff{Interval: time.Minute}})
+
+	cb.Trip()
+	c.Add(time.Second)
+	if !cb.Ready() {
+		t.Fatal("expected the old backoff to apply until the next retry")
+	}
+
+	cb.Fail()
+	c.Add(time.Second)
+	if cb.Ready() {
+		t.Fatal("expected the new backoff to be used")
+	}
+	c.Add(time.Minute)
+	if !cb.Ready() {
+		t.Fatal("expected breaker to retry after the new backoff")
+	}
+}
@@ -0,0 +1,89 @@
+// Package circuitprom exports the breakers in a circuit.Panel as Prometheus
+// metrics.
+//
+//	prometheus.MustRegister(circuitprom.NewCollector(panel))
+package circuitprom
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	circuit "github.com/rubyist/circuitbreaker"
+)
+
+var states = []circuit.State{circuit.StateClosed, circuit.StateHalfOpen, circuit.StateOpen}
+
+// Collector is a prometheus.Collector reporting on every breaker in a Panel,
+// labeled by the breaker's name in the panel. Breakers added to the panel
+// after the Collector was created are picked up on the next scrape.
+type Collector struct {
+	panel *circuit.Panel
+
+	state          *prometheus.Desc
+	failures       *prometheus.Desc
+	successes      *prometheus.Desc
+	consecFailures *prometheus.Desc
+	errorRate      *prometheus.Desc
+	trips          *prometheus.Desc
+	openSeconds    *prometheus.Desc
+}
+
+// NewCollector creates a Collector for the breakers in panel.
+func NewCollector(panel *circuit.Panel) *Collector {
+	name := []string{"name"}
+	return &Collector{
+		panel: panel,
+		state: prometheus.NewDesc("circuit_breaker_state",
+			"Whether the breaker is in the given state.",
+			[]string{"name", "state"}, nil),
+		failures: prometheus.NewDesc("circuit_breaker_failures",
+			"Failures in the breaker's rolling window.", name, nil),
+		successes: prometheus.NewDesc("circuit_breaker_successes",
+			"Successes in the breaker's rolling window.", name, nil),
+		consecFailures: prometheus.NewDesc("circuit_breaker_consecutive_failures",
+			"Consecutive failures recorded by the breaker.", name, nil),
+		errorRate: prometheus.NewDesc("circuit_breaker_error_rate",
+			"Error rate over the breaker's rolling window.", name, nil),
+		trips: prometheus.NewDesc("circuit_breaker_trips_total",
+			"Number of times the breaker has tripped.", name, nil),
+		openSeconds: prometheus.NewDesc("circuit_breaker_open_seconds",
+			"Seconds since the breaker tripped, or 0 if it is not tripped.", name, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.state
+	ch <- c.failures
+	ch <- c.successes
+	ch <- c.consecFailures
+	ch <- c.errorRate
+	ch <- c.trips
+	ch <- c.openSeconds
+}
+
+// Collect implements prometheus.Collector.
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	for name, cb := range c.panel.Breakers() {
+		current := cb.State()
+		for _, s := range states {
+			value := 0.0
+			if s == current {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(c.state, prometheus.GaugeValue, value, name, s.String())
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.failures, prometheus.GaugeValue, float64(cb.Failures()), name)
+		ch <- prometheus.MustNewConstMetric(c.successes, prometheus.GaugeValue, float64(cb.Successes()), name)
+		ch <- prometheus.MustNewConstMetric(c.consecFailures, prometheus.GaugeValue, float64(cb.ConsecFailures()), name)
+		ch <- prometheus.MustNewConstMetric(c.errorRate, prometheus.GaugeValue, cb.ErrorRate(), name)
+		ch <- prometheus.MustNewConstMetric(c.trips, prometheus.CounterValue, float64(cb.Trips()), name)
+
+		open := 0.0
+		if at := cb.TrippedAt(); !at.IsZero() {
+			open = cb.Clock.Now().Sub(at).Seconds()
+		}
+		ch <- prometheus.MustNewConstMetric(c.openSeconds, prometheus.GaugeValue, open, name)
+	}
+}
+
+var _ prometheus.Collector = (*Collector)(nil)
@@ -0,0 +1,37 @@
+package circuitprom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	circuit "github.com/rubyist/circuitbreaker"
+)
+
+func TestCollector(t *testing.T) {
+	panel := circuit.NewPanel()
+	cb := circuit.NewThresholdBreaker(2)
+	panel.Add("payments", cb)
+	cb.Success()
+	cb.Fail()
+	cb.Fail()
+
+	expected := `
+# HELP circuit_breaker_failures Failures in the breaker's rolling window.
+# TYPE circuit_breaker_failures gauge
+circuit_breaker_failures{name="payments"} 2
+# HELP circuit_breaker_state Whether the breaker is in the given state.
+# TYPE circuit_breaker_state gauge
+circuit_breaker_state{name="payments",state="closed"} 0
+circuit_breaker_state{name="payments",state="half-open"} 0
+circuit_breaker_state{name="payments",state="open"} 1
+# HELP circuit_breaker_trips_total Number of times the breaker has tripped.
+# TYPE circuit_breaker_trips_total counter
+circuit_breaker_trips_total{name="payments"} 1
+`
+	err := testutil.CollectAndCompare(NewCollector(panel), strings.NewReader(expected),
+		"circuit_breaker_failures", "circuit_breaker_state", "circuit_breaker_trips_total")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
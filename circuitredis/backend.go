@@ -0,0 +1,63 @@
+// Package circuitredis provides a circuit.Backend storing breaker state in
+// Redis, so that breakers of the same name in different processes trip and
+// reset together.
+//
+//	backend := circuitredis.NewBackend(redis.NewClient(&redis.Options{Addr: "localhost:6379"}), "circuit:")
+//	cb := circuit.NewBreakerWithOptions(&circuit.Options{
+//		Name:       "payments",
+//		ShouldTrip: circuit.ThresholdTripFunc(10),
+//		Backend:    backend,
+//	})
+package circuitredis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	circuit "github.com/rubyist/circuitbreaker"
+)
+
+// DefaultTTL is the default time a published state is kept in Redis.
+var DefaultTTL = 24 * time.Hour
+
+// Backend is a circuit.Backend storing each breaker's state as JSON under the
+// key prefix followed by the breaker's name.
+type Backend struct {
+	// TTL is how long a published state is kept. Zero keeps it forever.
+	TTL time.Duration
+
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewBackend creates a Backend using client, with keys starting with prefix.
+func NewBackend(client redis.UniversalClient, prefix string) *Backend {
+	return &Backend{TTL: DefaultTTL, client: client, prefix: prefix}
+}
+
+// Publish implements circuit.Backend.
+func (b *Backend) Publish(ctx context.Context, name string, state circuit.BackendState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return b.client.Set(ctx, b.prefix+name, data, b.TTL).Err()
+}
+
+// Fetch implements circuit.Backend.
+func (b *Backend) Fetch(ctx context.Context, name string) (state circuit.BackendState, ok bool, err error) {
+	data, err := b.client.Get(ctx, b.prefix+name).Bytes()
+	if err == redis.Nil {
+		return state, false, nil
+	}
+	if err != nil {
+		return state, false, err
+	}
+
+	err = json.Unmarshal(data, &state)
+	return state, err == nil, err
+}
+
+var _ circuit.Backend = (*Backend)(nil)
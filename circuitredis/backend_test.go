@@ -0,0 +1,37 @@
+package circuitredis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	circuit "github.com/rubyist/circuitbreaker"
+)
+
+func TestBackend(t *testing.T) {
+	server := miniredis.RunT(t)
+	backend := NewBackend(redis.NewClient(&redis.Options{Addr: server.Addr()}), "circuit:")
+	ctx := context.Background()
+
+	if _, ok, err := backend.Fetch(ctx, "payments"); ok || err != nil {
+		t.Fatalf("expected no state, got ok=%v err=%v", ok, err)
+	}
+
+	published := circuit.BackendState{Tripped: true, Updated: time.Unix(1, 0).UTC()}
+	if err := backend.Publish(ctx, "payments", published); err != nil {
+		t.Fatal(err)
+	}
+	if !server.Exists("circuit:payments") {
+		t.Fatal("expected the state to be stored under the prefixed key")
+	}
+
+	state, ok, err := backend.Fetch(ctx, "payments")
+	if err != nil || !ok {
+		t.Fatalf("expected a state, got ok=%v err=%v", ok, err)
+	}
+	if state != published {
+		t.Fatalf("expected %+v, got %+v", published, state)
+	}
+}
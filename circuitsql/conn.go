@@ -0,0 +1,350 @@
+package circuitsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"reflect"
+)
+
+// wrappedConn protects the calls to a connection that reach the database.
+// Optional interfaces its base does not implement are reported with
+// driver.ErrSkip or their documented defaults, so database/sql falls back as
+// it would for the base connection.
+type wrappedConn struct {
+	base driver.Conn
+	c    *Connector
+}
+
+var (
+	_ driver.Conn               = (*wrappedConn)(nil)
+	_ driver.ConnPrepareContext = (*wrappedConn)(nil)
+	_ driver.ConnBeginTx        = (*wrappedConn)(nil)
+	_ driver.ExecerContext      = (*wrappedConn)(nil)
+	_ driver.QueryerContext     = (*wrappedConn)(nil)
+	_ driver.Pinger             = (*wrappedConn)(nil)
+	_ driver.SessionResetter    = (*wrappedConn)(nil)
+	_ driver.Validator          = (*wrappedConn)(nil)
+	_ driver.NamedValueChecker  = (*wrappedConn)(nil)
+)
+
+func (wc *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return wc.PrepareContext(context.Background(), query)
+}
+
+func (wc *wrappedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	var stmt driver.Stmt
+	err := wc.c.call(ctx, func(ctx context.Context) error {
+		var err error
+		if cp, ok := wc.base.(driver.ConnPrepareContext); ok {
+			stmt, err = cp.PrepareContext(ctx, query)
+		} else {
+			stmt, err = wc.base.Prepare(query)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedStmt{base: stmt, c: wc.c}, nil
+}
+
+func (wc *wrappedConn) Close() error {
+	return wc.base.Close()
+}
+
+func (wc *wrappedConn) Begin() (driver.Tx, error) {
+	return wc.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (wc *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if _, ok := wc.base.(driver.ConnBeginTx); !ok {
+		// database/sql rejects options it cannot pass to Begin.
+		if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+			return nil, errIsolationLevel
+		}
+		if opts.ReadOnly {
+			return nil, errReadOnly
+		}
+	}
+
+	var tx driver.Tx
+	done, err := wc.c.start(ctx, func(ctx context.Context) error {
+		var err error
+		if cb, ok := wc.base.(driver.ConnBeginTx); ok {
+			tx, err = cb.BeginTx(ctx, opts)
+		} else {
+			tx, err = wc.base.Begin()
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedTx{base: tx, done: done}, nil
+}
+
+func (wc *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	ec, ok := wc.base.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	var result driver.Result
+	err := wc.c.call(ctx, func(ctx context.Context) error {
+		var err error
+		result, err = ec.ExecContext(ctx, query, args)
+		return err
+	})
+	return result, err
+}
+
+func (wc *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	qc, ok := wc.base.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	var rows driver.Rows
+	done, err := wc.c.start(ctx, func(ctx context.Context) error {
+		var err error
+		rows, err = qc.QueryContext(ctx, query, args)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedRows{base: rows, done: done}, nil
+}
+
+func (wc *wrappedConn) Ping(ctx context.Context) error {
+	p, ok := wc.base.(driver.Pinger)
+	if !ok {
+		return nil
+	}
+	return wc.c.call(ctx, p.Ping)
+}
+
+func (wc *wrappedConn) ResetSession(ctx context.Context) error {
+	if sr, ok := wc.base.(driver.SessionResetter); ok {
+		return sr.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (wc *wrappedConn) IsValid() bool {
+	if v, ok := wc.base.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (wc *wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := wc.base.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// wrappedStmt protects the executions of a prepared statement.
+type wrappedStmt struct {
+	base driver.Stmt
+	c    *Connector
+}
+
+var (
+	_ driver.Stmt              = (*wrappedStmt)(nil)
+	_ driver.StmtExecContext   = (*wrappedStmt)(nil)
+	_ driver.StmtQueryContext  = (*wrappedStmt)(nil)
+	_ driver.NamedValueChecker = (*wrappedStmt)(nil)
+)
+
+func (ws *wrappedStmt) Close() error {
+	return ws.base.Close()
+}
+
+func (ws *wrappedStmt) NumInput() int {
+	return ws.base.NumInput()
+}
+
+func (ws *wrappedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return ws.ExecContext(context.Background(), namedValues(args))
+}
+
+func (ws *wrappedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return ws.QueryContext(context.Background(), namedValues(args))
+}
+
+func (ws *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	var result driver.Result
+	err := ws.c.call(ctx, func(ctx context.Context) error {
+		var err error
+		if se, ok := ws.base.(driver.StmtExecContext); ok {
+			result, err = se.ExecContext(ctx, args)
+			return err
+		}
+		values, err := plainValues(args)
+		if err != nil {
+			return err
+		}
+		result, err = ws.base.Exec(values)
+		return err
+	})
+	return result, err
+}
+
+func (ws *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	var rows driver.Rows
+	done, err := ws.c.start(ctx, func(ctx context.Context) error {
+		var err error
+		if sq, ok := ws.base.(driver.StmtQueryContext); ok {
+			rows, err = sq.QueryContext(ctx, args)
+			return err
+		}
+		values, err := plainValues(args)
+		if err != nil {
+			return err
+		}
+		rows, err = ws.base.Query(values)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedRows{base: rows, done: done}, nil
+}
+
+func (ws *wrappedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if nvc, ok := ws.base.(driver.NamedValueChecker); ok {
+		return nvc.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// wrappedTx releases the context its transaction was begun with once it is
+// committed or rolled back.
+type wrappedTx struct {
+	base driver.Tx
+	done func()
+}
+
+func (tx *wrappedTx) Commit() error {
+	defer tx.done()
+	return tx.base.Commit()
+}
+
+func (tx *wrappedTx) Rollback() error {
+	defer tx.done()
+	return tx.base.Rollback()
+}
+
+// wrappedRows releases the context its query was started with once it is
+// closed. Like wrappedConn, it reports the documented defaults for optional
+// interfaces its base does not implement.
+type wrappedRows struct {
+	base driver.Rows
+	done func()
+}
+
+var (
+	_ driver.Rows                           = (*wrappedRows)(nil)
+	_ driver.RowsNextResultSet              = (*wrappedRows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*wrappedRows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*wrappedRows)(nil)
+	_ driver.RowsColumnTypeLength           = (*wrappedRows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*wrappedRows)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*wrappedRows)(nil)
+)
+
+func (wr *wrappedRows) Columns() []string {
+	return wr.base.Columns()
+}
+
+func (wr *wrappedRows) Close() error {
+	defer wr.done()
+	return wr.base.Close()
+}
+
+func (wr *wrappedRows) Next(dest []driver.Value) error {
+	return wr.base.Next(dest)
+}
+
+func (wr *wrappedRows) HasNextResultSet() bool {
+	if rs, ok := wr.base.(driver.RowsNextResultSet); ok {
+		return rs.HasNextResultSet()
+	}
+	return false
+}
+
+func (wr *wrappedRows) NextResultSet() error {
+	if rs, ok := wr.base.(driver.RowsNextResultSet); ok {
+		return rs.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (wr *wrappedRows) ColumnTypeScanType(index int) reflect.Type {
+	if ct, ok := wr.base.(driver.RowsColumnTypeScanType); ok {
+		return ct.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+func (wr *wrappedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if ct, ok := wr.base.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return ct.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (wr *wrappedRows) ColumnTypeLength(index int) (length int64, ok bool) {
+	if ct, ok := wr.base.(driver.RowsColumnTypeLength); ok {
+		return ct.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (wr *wrappedRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if ct, ok := wr.base.(driver.RowsColumnTypeNullable); ok {
+		return ct.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (wr *wrappedRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if ct, ok := wr.base.(driver.RowsColumnTypePrecisionScale); ok {
+		return ct.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+var (
+	errNamedArgs = errors.New("circuitsql: driver does not support named arguments")
+
+	// errIsolationLevel and errReadOnly are the errors database/sql returns
+	// for transaction options a driver without driver.ConnBeginTx does not
+	// support.
+	errIsolationLevel = errors.New("sql: driver does not support non-default isolation level")
+	errReadOnly       = errors.New("sql: driver does not support read-only transactions")
+)
+
+func namedValues(args []driver.Value) []driver.NamedValue {
+	named := make([]driver.NamedValue, len(args))
+	for i, v := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: v}
+	}
+	return named
+}
+
+func plainValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			return nil, errNamedArgs
+		}
+		values[i] = arg.Value
+	}
+	return values, nil
+}
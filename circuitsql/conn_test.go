@@ -0,0 +1,130 @@
+package circuitsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	circuit "github.com/rubyist/circuitbreaker"
+)
+
+// prepareOnlyConn only implements driver.Conn, so database/sql prepares a
+// statement for every query.
+type prepareOnlyConn struct {
+	base *fakeConn
+}
+
+func (c prepareOnlyConn) Prepare(query string) (driver.Stmt, error) {
+	return c.base.Prepare(query)
+}
+
+func (c prepareOnlyConn) Close() error {
+	return c.base.Close()
+}
+
+func (c prepareOnlyConn) Begin() (driver.Tx, error) {
+	return c.base.Begin()
+}
+
+type prepareOnlyConnector struct {
+	d *fakeDriver
+}
+
+func (c prepareOnlyConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return prepareOnlyConn{&fakeConn{d: c.d}}, nil
+}
+
+func (c prepareOnlyConnector) Driver() driver.Driver {
+	return c.d
+}
+
+func TestPreparedStatements(t *testing.T) {
+	d := &fakeDriver{}
+	cb := circuit.NewThresholdBreaker(2)
+	db := sql.OpenDB(NewConnector(prepareOnlyConnector{d}, cb, 0))
+	defer db.Close()
+
+	if _, err := db.Exec("UPDATE t SET n = ?", 1); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := db.Query("SELECT n FROM t WHERE n = ?", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows.Close()
+	if n := atomic.LoadInt32(&d.queries); n != 2 {
+		t.Fatalf("expected 2 queries, got %d", n)
+	}
+
+	atomic.StoreInt32(&d.down, 1)
+	if _, err := db.Exec("UPDATE t SET n = ?", 1); !errors.Is(err, errDown) {
+		t.Fatalf("expected the database's error, got %v", err)
+	}
+	if _, err := db.Exec("UPDATE t SET n = ?", 1); !errors.Is(err, errDown) {
+		t.Fatalf("expected the database's error, got %v", err)
+	}
+	if !cb.Tripped() {
+		t.Fatal("expected failed statements to trip the breaker")
+	}
+	if _, err := db.Exec("UPDATE t SET n = ?", 1); err != circuit.ErrBreakerOpen {
+		t.Fatalf("expected the open breaker to reject the statement, got %v", err)
+	}
+}
+
+func TestBeginTxOptionsWithoutConnBeginTx(t *testing.T) {
+	d := &fakeDriver{}
+	cb := circuit.NewThresholdBreaker(1)
+	db := sql.OpenDB(NewConnector(prepareOnlyConnector{d}, cb, 0))
+	defer db.Close()
+
+	ctx := context.Background()
+	if _, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelSerializable}); err == nil || err.Error() != errIsolationLevel.Error() {
+		t.Fatalf("expected the isolation level to be rejected, got %v", err)
+	}
+	if _, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true}); err == nil || err.Error() != errReadOnly.Error() {
+		t.Fatalf("expected a read-only transaction to be rejected, got %v", err)
+	}
+}
+
+// skipConn refuses to execute directly, so database/sql prepares a statement
+// instead.
+type skipConn struct {
+	*fakeConn
+}
+
+func (c skipConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return nil, driver.ErrSkip
+}
+
+type skipConnector struct {
+	d *fakeDriver
+}
+
+func (c skipConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return skipConn{&fakeConn{d: c.d}}, nil
+}
+
+func (c skipConnector) Driver() driver.Driver {
+	return c.d
+}
+
+func TestSkipIsNotRecorded(t *testing.T) {
+	d := &fakeDriver{}
+	cb := circuit.NewThresholdBreaker(1)
+	db := sql.OpenDB(NewConnector(skipConnector{d}, cb, 0))
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	successes := cb.Successes()
+	if _, err := db.Exec("UPDATE t SET n = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if n := cb.Successes() - successes; n != 2 {
+		t.Fatalf("expected only the prepare and the statement's exec to be recorded, got %d successes", n)
+	}
+}
@@ -0,0 +1,227 @@
+// Package circuitsql wraps database/sql drivers so that connecting, pinging,
+// preparing, querying and executing go through a circuit breaker, and calls
+// fail fast with circuit.ErrBreakerOpen while the database is down.
+//
+// Wrap a driver.Connector to protect a single database with one breaker:
+//
+//	db := sql.OpenDB(circuitsql.NewConnector(connector, circuit.NewThresholdBreaker(10), time.Second))
+//
+// or register a wrapped driver.Driver to get one breaker per DSN:
+//
+//	sql.Register("circuit-postgres", circuitsql.NewDriver(&pq.Driver{}, circuit.NewThresholdBreaker(10), time.Second))
+//	db, err := sql.Open("circuit-postgres", dsn)
+//
+// Only starting a query is protected; errors from iterating its rows are not
+// recorded, and the time out does not apply to them. Transactions are begun
+// through the breaker, but their commit and rollback are not.
+package circuitsql
+
+import (
+	"context"
+	"database/sql/driver"
+	"time"
+
+	circuit "github.com/rubyist/circuitbreaker"
+)
+
+// DefaultClassifier treats every error as a failure.
+func DefaultClassifier(err error) bool {
+	return true
+}
+
+// Driver is a driver.Driver whose connections are protected by breakers from
+// Panel, one per DSN.
+type Driver struct {
+	// Panel holds the driver's breakers. They are created with its
+	// GetOrCreate; NewDriver sets its Factory to clone the template. A
+	// shared panel can be used instead, to create the breakers with its
+	// factories and name them with KeyFunc.
+	Panel *circuit.Panel
+
+	// KeyFunc returns the name of the breaker for a DSN. If nil, the DSN
+	// itself is used. A DSN may hold credentials, so set KeyFunc before
+	// exporting the panel's stats.
+	KeyFunc func(dsn string) string
+
+	// Classifier decides whether an error returned by the database is a
+	// failure. If nil, DefaultClassifier is used.
+	Classifier func(err error) bool
+
+	base    driver.Driver
+	timeout time.Duration
+}
+
+// NewDriver creates a Driver opening connections with base. The breaker for
+// each DSN is created with template.CloneConfig(). Calls taking longer than
+// timeout are canceled and fail with circuit.ErrBreakerTimeout; specifying 0
+// gives breakers that do not check for time outs.
+func NewDriver(base driver.Driver, template *circuit.Breaker, timeout time.Duration) *Driver {
+	panel := circuit.NewPanel()
+	panel.Factory = func(string) *circuit.Breaker {
+		return template.CloneConfig()
+	}
+	return &Driver{Panel: panel, base: base, timeout: timeout}
+}
+
+// Open implements driver.Driver.
+func (d *Driver) Open(dsn string) (driver.Conn, error) {
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return c.Connect(context.Background())
+}
+
+// OpenConnector implements driver.DriverContext.
+func (d *Driver) OpenConnector(dsn string) (driver.Connector, error) {
+	var base driver.Connector = dsnConnector{dsn: dsn, driver: d.base}
+	if dc, ok := d.base.(driver.DriverContext); ok {
+		var err error
+		if base, err = dc.OpenConnector(dsn); err != nil {
+			return nil, err
+		}
+	}
+
+	c := NewConnector(base, d.Breaker(dsn), d.timeout)
+	c.Classifier = d.Classifier
+	c.driver = d
+	return c, nil
+}
+
+// Breaker returns the breaker for dsn, creating it if needed.
+func (d *Driver) Breaker(dsn string) *circuit.Breaker {
+	key := dsn
+	if d.KeyFunc != nil {
+		key = d.KeyFunc(dsn)
+	}
+	return d.Panel.GetOrCreate(key).(*circuit.Breaker)
+}
+
+// Connector is a driver.Connector whose connections are protected by a
+// single breaker.
+type Connector struct {
+	// Classifier decides whether an error returned by the database is a
+	// failure. If nil, DefaultClassifier is used.
+	Classifier func(err error) bool
+
+	base    driver.Connector
+	breaker *circuit.Breaker
+	timeout time.Duration
+	driver  driver.Driver
+}
+
+// NewConnector creates a Connector connecting with base, protected by cb.
+// Calls taking longer than timeout are canceled and fail with
+// circuit.ErrBreakerTimeout; specifying 0 gives a breaker that does not check
+// for time outs.
+func NewConnector(base driver.Connector, cb *circuit.Breaker, timeout time.Duration) *Connector {
+	return &Connector{base: base, breaker: cb, timeout: timeout}
+}
+
+// Connect implements driver.Connector.
+func (c *Connector) Connect(ctx context.Context) (driver.Conn, error) {
+	var conn driver.Conn
+	err := c.call(ctx, func(ctx context.Context) error {
+		var err error
+		conn, err = c.base.Connect(ctx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &wrappedConn{base: conn, c: c}, nil
+}
+
+// Driver implements driver.Connector.
+func (c *Connector) Driver() driver.Driver {
+	if c.driver != nil {
+		return c.driver
+	}
+	return connectorDriver{c}
+}
+
+// Breaker returns the connector's breaker.
+func (c *Connector) Breaker() *circuit.Breaker {
+	return c.breaker
+}
+
+// call runs fn through the connector's breaker. The call is made in the
+// caller's goroutine, since driver connections must not be used concurrently;
+// its time out is applied by canceling fn's context.
+func (c *Connector) call(ctx context.Context, fn func(ctx context.Context) error) error {
+	done, err := c.start(ctx, fn)
+	done()
+	return err
+}
+
+// start is call for rows and transactions, which go on using fn's context
+// after it returns. If fn succeeds its context is left open, and no longer
+// times out, until done is called.
+func (c *Connector) start(ctx context.Context, fn func(ctx context.Context) error) (done func(), err error) {
+	callCtx, cancel := context.WithCancelCause(ctx)
+	done = func() { cancel(nil) }
+
+	var timer *time.Timer
+	if c.timeout > 0 {
+		timer = time.AfterFunc(c.timeout, func() {
+			cancel(context.DeadlineExceeded)
+		})
+	}
+
+	err = c.breaker.CallWithOptions(func() error {
+		err := fn(callCtx)
+		if err != nil && ctx.Err() == nil && context.Cause(callCtx) == context.DeadlineExceeded {
+			return circuit.ErrBreakerTimeout
+		}
+		return err
+	}, circuit.WithContext(ctx), circuit.WithClassifier(c.isFailure), circuit.WithIgnored(isSkip))
+
+	if timer != nil {
+		timer.Stop()
+	}
+	if err != nil {
+		done()
+	}
+	return done, err
+}
+
+func (c *Connector) isFailure(err error) bool {
+	if c.Classifier != nil {
+		return c.Classifier(err)
+	}
+	return DefaultClassifier(err)
+}
+
+// isSkip reports whether err is driver.ErrSkip, returned when the base
+// connection did not run the call and database/sql is to fall back. Nothing
+// reached the database, so no outcome is recorded.
+func isSkip(err error) bool {
+	return err == driver.ErrSkip
+}
+
+// connectorDriver is the driver.Driver of a Connector, opening connections
+// with its base driver and breaker.
+type connectorDriver struct {
+	c *Connector
+}
+
+func (d connectorDriver) Open(dsn string) (driver.Conn, error) {
+	base := dsnConnector{dsn: dsn, driver: d.c.base.Driver()}
+	c := &Connector{Classifier: d.c.Classifier, base: base, breaker: d.c.breaker, timeout: d.c.timeout}
+	return c.Connect(context.Background())
+}
+
+// dsnConnector is a driver.Connector for drivers that do not implement
+// driver.DriverContext.
+type dsnConnector struct {
+	dsn    string
+	driver driver.Driver
+}
+
+func (c dsnConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
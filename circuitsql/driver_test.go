@@ -0,0 +1,315 @@
+package circuitsql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	circuit "github.com/rubyist/circuitbreaker"
+)
+
+var errDown = errors.New("database is down")
+
+// fakeDriver is a database driver whose connections fail while down is set,
+// and block until their context is done while hang is set.
+type fakeDriver struct {
+	down    int32
+	hang    int32
+	queries int32
+}
+
+func (d *fakeDriver) Open(dsn string) (driver.Conn, error) {
+	if atomic.LoadInt32(&d.down) == 1 {
+		return nil, errDown
+	}
+	return &fakeConn{d: d}, nil
+}
+
+func (d *fakeDriver) Connect(ctx context.Context) (driver.Conn, error) {
+	return d.Open("")
+}
+
+func (d *fakeDriver) Driver() driver.Driver {
+	return d
+}
+
+func (d *fakeDriver) query(ctx context.Context) error {
+	atomic.AddInt32(&d.queries, 1)
+	if atomic.LoadInt32(&d.hang) == 1 {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	if atomic.LoadInt32(&d.down) == 1 {
+		return errDown
+	}
+	return nil
+}
+
+type fakeConn struct {
+	d *fakeDriver
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{d: c.d}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("transactions are not supported")
+}
+
+func (c *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if err := c.d.query(ctx); err != nil {
+		return nil, err
+	}
+	return fakeTx{ctx}, nil
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	if err := c.d.query(ctx); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if err := c.d.query(ctx); err != nil {
+		return nil, err
+	}
+	return fakeRows{ctx}, nil
+}
+
+func (c *fakeConn) Ping(ctx context.Context) error {
+	return c.d.query(ctx)
+}
+
+type fakeStmt struct {
+	d *fakeDriver
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := s.d.query(context.Background()); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := s.d.query(context.Background()); err != nil {
+		return nil, err
+	}
+	return fakeRows{context.Background()}, nil
+}
+
+// fakeRows and fakeTx fail once the context they were started with is done,
+// like a driver that watches it for their whole lifetime.
+type fakeRows struct {
+	ctx context.Context
+}
+
+func (fakeRows) Columns() []string {
+	return []string{"n"}
+}
+
+func (fakeRows) Close() error {
+	return nil
+}
+
+func (r fakeRows) Next(dest []driver.Value) error {
+	if err := r.ctx.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+type fakeTx struct {
+	ctx context.Context
+}
+
+func (tx fakeTx) Commit() error {
+	return tx.ctx.Err()
+}
+
+func (tx fakeTx) Rollback() error {
+	return tx.ctx.Err()
+}
+
+func TestConnector(t *testing.T) {
+	d := &fakeDriver{}
+	cb := circuit.NewThresholdBreaker(2)
+	db := sql.OpenDB(NewConnector(d, cb, 0))
+	defer db.Close()
+
+	if _, err := db.Exec("UPDATE t SET n = 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if cb.Successes() != 3 {
+		t.Fatalf("expected the connect, exec and ping to succeed, got %d successes", cb.Successes())
+	}
+
+	atomic.StoreInt32(&d.down, 1)
+	for i := 0; i < 2; i++ {
+		if _, err := db.Exec("UPDATE t SET n = 1"); !errors.Is(err, errDown) {
+			t.Fatalf("expected the database's error, got %v", err)
+		}
+	}
+	if !cb.Tripped() {
+		t.Fatal("expected the breaker to trip")
+	}
+
+	queries := atomic.LoadInt32(&d.queries)
+	if _, err := db.Query("SELECT n FROM t"); err != circuit.ErrBreakerOpen {
+		t.Fatalf("expected the open breaker to reject the query, got %v", err)
+	}
+	if err := db.Ping(); err != circuit.ErrBreakerOpen {
+		t.Fatalf("expected the open breaker to reject the ping, got %v", err)
+	}
+	if n := atomic.LoadInt32(&d.queries); n != queries {
+		t.Fatalf("expected the database not to be called, got %d calls", n-queries)
+	}
+}
+
+func TestConnectorTimeout(t *testing.T) {
+	d := &fakeDriver{}
+	cb := circuit.NewBreakerWithOptions(&circuit.Options{ShouldTrip: circuit.TimeoutTripFunc(1)})
+	db := sql.OpenDB(NewConnector(d, cb, 10*time.Millisecond))
+	defer db.Close()
+
+	atomic.StoreInt32(&d.hang, 1)
+	if _, err := db.Exec("UPDATE t SET n = 1"); err != circuit.ErrBreakerTimeout {
+		t.Fatalf("expected a time out, got %v", err)
+	}
+	if cb.Timeouts() != 1 || !cb.Tripped() {
+		t.Fatal("expected the time out to trip the breaker")
+	}
+}
+
+func TestConnectorRowsOutliveCall(t *testing.T) {
+	d := &fakeDriver{}
+	cb := circuit.NewThresholdBreaker(1)
+	db := sql.OpenDB(NewConnector(d, cb, 10*time.Millisecond))
+	defer db.Close()
+
+	rows, err := db.Query("SELECT n FROM t")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	for rows.Next() {
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("expected the rows' context to stay open until they are closed, got %v", err)
+	}
+	rows.Close()
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("expected the transaction's context to stay open until it is committed, got %v", err)
+	}
+}
+
+func TestConnectorCanceled(t *testing.T) {
+	d := &fakeDriver{}
+	cb := circuit.NewThresholdBreaker(1)
+	db := sql.OpenDB(NewConnector(d, cb, 0))
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&d.hang, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if _, err := db.ExecContext(ctx, "UPDATE t SET n = 1"); err == nil {
+		t.Fatal("expected the canceled exec to fail")
+	}
+	if cb.Tripped() {
+		t.Fatal("expected the caller's cancellation not to trip the breaker")
+	}
+}
+
+func TestConnectorClassifier(t *testing.T) {
+	d := &fakeDriver{}
+	cb := circuit.NewThresholdBreaker(1)
+	connector := NewConnector(d, cb, 0)
+	connector.Classifier = func(err error) bool {
+		return err != errDown
+	}
+	db := sql.OpenDB(connector)
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&d.down, 1)
+	if _, err := db.Exec("UPDATE t SET n = 1"); !errors.Is(err, errDown) {
+		t.Fatalf("expected the database's error, got %v", err)
+	}
+	if cb.Tripped() {
+		t.Fatal("expected an error the classifier accepts not to trip the breaker")
+	}
+}
+
+func TestDriver(t *testing.T) {
+	d := &fakeDriver{}
+	wrapped := NewDriver(d, circuit.NewThresholdBreaker(1), 0)
+	wrapped.KeyFunc = func(dsn string) string {
+		return "db." + dsn
+	}
+
+	open := func(dsn string) *sql.DB {
+		c, err := wrapped.OpenConnector(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sql.OpenDB(c)
+	}
+	a := open("a")
+	defer a.Close()
+	b := open("b")
+	defer b.Close()
+
+	if err := a.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := a.Driver().(*Driver); !ok {
+		t.Fatalf("expected the wrapped driver, got %T", a.Driver())
+	}
+
+	atomic.StoreInt32(&d.down, 1)
+	a.Exec("UPDATE t SET n = 1")
+	if !wrapped.Breaker("a").Tripped() {
+		t.Fatal("expected a's breaker to trip")
+	}
+	if wrapped.Breaker("b").Tripped() {
+		t.Fatal("expected b's breaker to be unaffected")
+	}
+	if _, ok := wrapped.Panel.Get("db.a"); !ok {
+		t.Fatal("expected the breaker to be named by KeyFunc")
+	}
+}
@@ -1,9 +1,15 @@
 package circuit
 
 import (
+	"context"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -12,17 +18,158 @@ import (
 // By default, the client will use its defaultBreaker. A BreakerLookup function may be
 // provided to allow different breakers to be used based on the circumstance. See the
 // implementation of NewHostBasedHTTPClient for an example of this.
+//
+// Requests are made with their own context, which is passed on to the breaker
+// as with CallContext: a request canceled by its caller is not recorded as a
+// failure.
 type HTTPClient struct {
 	Client         *http.Client
 	BreakerTripped func()
 	BreakerReset   func()
 	BreakerLookup  func(*HTTPClient, interface{}) *Breaker
 	Panel          *Panel
-	timeout        time.Duration
+
+	// KeyFunc, when set, returns the name of the breaker for a request, such
+	// as its host and path template or a tenant ID taken from a header. It
+	// takes precedence over BreakerLookup and Resolver. Each name gets its
+	// own breaker, created like the client's host breakers, or from the
+	// default breaker's configuration if the client is not host based.
+	KeyFunc func(*http.Request) string
+
+	// Resolver, when set on a host based client, makes the client keep one
+	// breaker per resolved backend address rather than per host, so a single
+	// bad instance behind a load balanced hostname only trips its own breaker.
+	// Requests are sent to an address whose breaker is not open. For example:
+	//
+	//	client.Resolver = net.DefaultResolver.LookupHost
+	//
+	// Requests can only be pinned to an address when the client's Transport is
+	// an *http.Transport (or nil); connections are then dialed directly,
+	// bypassing any proxy.
+	Resolver func(ctx context.Context, host string) ([]string, error)
+
+	// Classifier decides whether a response is a failure for the request's
+	// breaker. If nil, DefaultResponseClassifier is used. The response is
+	// returned to the caller either way.
+	Classifier func(*http.Response) error
+
+	timeout     time.Duration
+	prefix      string
+	template    *Breaker
+	addrClients map[string]*http.Client
+	addrLock    sync.Mutex
+	nextAddr    uint32
 }
 
 var defaultBreakerName = "_default"
 
+// StatusError is recorded as a breaker's failure when a response is classified
+// as one by DefaultResponseClassifier.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s responded with status %d", e.URL, e.StatusCode)
+}
+
+// DefaultResponseClassifier treats responses with a 5xx or 429 status code as
+// failures, returning a *StatusError for them, so that a server that keeps
+// responding with errors trips its breaker.
+func DefaultResponseClassifier(resp *http.Response) error {
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		e := &StatusError{StatusCode: resp.StatusCode}
+		if resp.Request != nil && resp.Request.URL != nil {
+			e.URL = resp.Request.URL.String()
+		}
+		return e
+	}
+	return nil
+}
+
+// responseFailure wraps the error of a response classified as a failure, to
+// tell it apart from errors returned in place of a response.
+type responseFailure struct {
+	err error
+}
+
+func (f *responseFailure) Error() string {
+	return f.err.Error()
+}
+
+func (f *responseFailure) Unwrap() error {
+	return f.err
+}
+
+// doHTTP sends a request with send, protected by cb. Responses that classify
+// returns an error for are recorded as failures but still returned, without
+// an error. If classify is nil, DefaultResponseClassifier is used.
+//
+// send is passed the context to send the request with. It is canceled if the
+// call fails or times out, and otherwise when the response body is closed,
+// since the body is read after the call returns. The body of a response that
+// arrives after the call timed out is closed.
+func doHTTP(ctx context.Context, cb *Breaker, classify func(*http.Response) error, send func(ctx context.Context) (*http.Response, error), timeout time.Duration) (*http.Response, error) {
+	if classify == nil {
+		classify = DefaultResponseClassifier
+	}
+
+	reqCtx, cancel := context.WithCancel(ctx)
+	sent := make(chan *http.Response, 1)
+	var started int32
+	err := cb.call(func(context.Context) error {
+		atomic.StoreInt32(&started, 1)
+		resp, err := send(reqCtx)
+		sent <- resp
+		if err != nil {
+			return err
+		}
+		if err := classify(resp); err != nil {
+			return &responseFailure{err}
+		}
+		return nil
+	}, &callOptions{ctx: ctx, timeout: timeout})
+
+	if err == ErrBreakerTimeout {
+		cancel()
+		go func() {
+			if resp := <-sent; resp != nil {
+				resp.Body.Close()
+			}
+		}()
+		return nil, err
+	}
+	if atomic.LoadInt32(&started) == 0 {
+		cancel()
+		return nil, err
+	}
+
+	resp := <-sent
+	if _, ok := err.(*responseFailure); err != nil && !ok {
+		cancel()
+		if resp != nil {
+			resp.Body.Close()
+		}
+		return nil, err
+	}
+	resp.Body = &cancelBody{resp.Body, cancel}
+	return resp, nil
+}
+
+// cancelBody is a response body that cancels its request's context when it
+// is closed.
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
 // NewHTTPClient provides a circuit breaker wrapper around http.Client.
 // It wraps all of the regular http.Client functions. Specifying 0 for timeout will
 // give a breaker that does not check for time outs.
@@ -36,42 +183,67 @@ func NewHTTPClient(timeout time.Duration, threshold int64, client *http.Client)
 // you to use a single HTTPClient for multiple hosts with one host's breaker not affecting
 // the other hosts.
 func NewHostBasedHTTPClient(timeout time.Duration, threshold int64, client *http.Client) *HTTPClient {
-	brclient := NewHTTPClient(timeout, threshold, client)
+	breaker := NewThresholdBreaker(threshold)
+	return NewHostBasedHTTPClientWithBreaker(breaker, timeout, client)
+}
+
+// NewHostBasedHTTPClientWithBreaker is like NewHostBasedHTTPClient, but the
+// breaker for each host is created with template.CloneConfig(), so every host
+// gets a breaker configured like template. The template itself is used as
+// the client's default breaker.
+func NewHostBasedHTTPClientWithBreaker(template *Breaker, timeout time.Duration, client *http.Client) *HTTPClient {
+	return NewHostBasedHTTPClientWithPanel(NewPanel(), "", template, timeout, client)
+}
+
+// NewHostBasedHTTPClientWithPanel is like NewHostBasedHTTPClientWithBreaker, but
+// the client's breakers are registered in panel instead of a panel of its own,
+// so they gain that panel's stats, events and administration. Breakers are
+// named prefix followed by the host (or the address, when a Resolver is set),
+// and the default breaker is named prefix followed by "_default".
+func NewHostBasedHTTPClientWithPanel(panel *Panel, prefix string, template *Breaker, timeout time.Duration, client *http.Client) *HTTPClient {
+	brclient := newHTTPClient(panel, prefix, template, timeout, client)
+	brclient.template = template
 
 	brclient.BreakerLookup = func(c *HTTPClient, val interface{}) *Breaker {
 		rawURL := val.(string)
 		parsedURL, err := url.Parse(rawURL)
 		if err != nil {
-			breaker, _ := c.Panel.Get(defaultBreakerName)
+			breaker, _ := c.Panel.Get(c.prefix + defaultBreakerName)
 			return breaker
 		}
-		host := parsedURL.Host
-
-		cb, ok := c.Panel.Get(host)
-		if !ok {
-			cb = NewThresholdBreaker(threshold)
-			c.Panel.Add(host, cb)
-		}
-
-		return cb
+		return c.keyBreaker(parsedURL.Host)
 	}
 
 	return brclient
 }
 
+// NewHostBasedRateHTTPClient is like NewHostBasedHTTPClient, but each host gets
+// a rate breaker rather than a threshold breaker. A host's breaker trips when
+// its error rate over a rolling window reaches rate, once at least minSamples
+// calls have been made in the window. The window covers windowTime and is
+// divided into windowBuckets buckets; zero values use DefaultWindowTime and
+// DefaultWindowBuckets.
+func NewHostBasedRateHTTPClient(timeout time.Duration, rate float64, minSamples int64, windowTime time.Duration, windowBuckets int, client *http.Client) *HTTPClient {
+	template := NewWindowedRateBreaker(rate, minSamples, windowTime, windowBuckets)
+	return NewHostBasedHTTPClientWithBreaker(template, timeout, client)
+}
+
 // NewHTTPClientWithBreaker provides a circuit breaker wrapper around http.Client.
 // It wraps all of the regular http.Client functions using the provided Breaker.
 func NewHTTPClientWithBreaker(breaker *Breaker, timeout time.Duration, client *http.Client) *HTTPClient {
+	return newHTTPClient(NewPanel(), "", breaker, timeout, client)
+}
+
+func newHTTPClient(panel *Panel, prefix string, breaker *Breaker, timeout time.Duration, client *http.Client) *HTTPClient {
 	if client == nil {
 		client = &http.Client{}
 	}
 
-	panel := NewPanel()
-	panel.Add(defaultBreakerName, breaker)
+	panel.Add(prefix+defaultBreakerName, breaker)
 
-	brclient := &HTTPClient{Client: client, Panel: panel, timeout: timeout}
+	brclient := &HTTPClient{Client: client, Panel: panel, prefix: prefix, timeout: timeout}
 	brclient.BreakerLookup = func(c *HTTPClient, val interface{}) *Breaker {
-		cb, _ := c.Panel.Get(defaultBreakerName)
+		cb, _ := c.Panel.Get(c.prefix + defaultBreakerName)
 		return cb
 	}
 
@@ -89,71 +261,176 @@ func NewHTTPClientWithBreaker(breaker *Breaker, timeout time.Duration, client *h
 	return brclient
 }
 
-// Do wraps http.Client Do()
+// Do wraps http.Client Do(). The request's context is honored by the breaker.
 func (c *HTTPClient) Do(req *http.Request) (*http.Response, error) {
-	var resp *http.Response
-	var err error
-	breaker := c.breakerLookup(req.URL.String())
-	err = breaker.Call(func() error {
-		resp, err = c.Client.Do(req)
-		return err
+	var breaker *Breaker
+	switch {
+	case c.KeyFunc != nil:
+		breaker = c.keyBreaker(c.KeyFunc(req))
+	case c.Resolver != nil && c.template != nil:
+		return c.doResolved(req)
+	default:
+		breaker = c.breakerLookup(req.URL.String())
+	}
+
+	return doHTTP(req.Context(), breaker, c.Classifier, func(ctx context.Context) (*http.Response, error) {
+		return c.Client.Do(req.WithContext(ctx))
 	}, c.timeout)
-	return resp, err
 }
 
 // Get wraps http.Client Get()
 func (c *HTTPClient) Get(url string) (*http.Response, error) {
-	var resp *http.Response
-	breaker := c.breakerLookup(url)
-	err := breaker.Call(func() error {
-		aresp, err := c.Client.Get(url)
-		resp = aresp
-		return err
-	}, c.timeout)
-	return resp, err
+	return c.GetContext(context.Background(), url)
+}
+
+// GetContext is like Get, but the request is made with ctx.
+func (c *HTTPClient) GetContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
 }
 
 // Head wraps http.Client Head()
 func (c *HTTPClient) Head(url string) (*http.Response, error) {
-	var resp *http.Response
-	breaker := c.breakerLookup(url)
-	err := breaker.Call(func() error {
-		aresp, err := c.Client.Head(url)
-		resp = aresp
-		return err
-	}, c.timeout)
-	return resp, err
+	return c.HeadContext(context.Background(), url)
+}
+
+// HeadContext is like Head, but the request is made with ctx.
+func (c *HTTPClient) HeadContext(ctx context.Context, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
 }
 
 // Post wraps http.Client Post()
 func (c *HTTPClient) Post(url string, bodyType string, body io.Reader) (*http.Response, error) {
-	var resp *http.Response
-	breaker := c.breakerLookup(url)
-	err := breaker.Call(func() error {
-		aresp, err := c.Client.Post(url, bodyType, body)
-		resp = aresp
-		return err
-	}, c.timeout)
-	return resp, err
+	return c.PostContext(context.Background(), url, bodyType, body)
+}
+
+// PostContext is like Post, but the request is made with ctx.
+func (c *HTTPClient) PostContext(ctx context.Context, url string, bodyType string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", bodyType)
+	return c.Do(req)
 }
 
 // PostForm wraps http.Client PostForm()
 func (c *HTTPClient) PostForm(url string, data url.Values) (*http.Response, error) {
-	var resp *http.Response
-	breaker := c.breakerLookup(url)
-	err := breaker.Call(func() error {
-		aresp, err := c.Client.PostForm(url, data)
-		resp = aresp
-		return err
+	return c.PostFormContext(context.Background(), url, data)
+}
+
+// PostFormContext is like PostForm, but the request is made with ctx.
+func (c *HTTPClient) PostFormContext(ctx context.Context, url string, data url.Values) (*http.Response, error) {
+	return c.PostContext(ctx, url, "application/x-www-form-urlencoded", strings.NewReader(data.Encode()))
+}
+
+// doResolved sends req to one of the addresses its host resolves to, using
+// the breaker for that address.
+func (c *HTTPClient) doResolved(req *http.Request) (*http.Response, error) {
+	host := req.URL.Hostname()
+	addrs := []string{host}
+	if net.ParseIP(host) == nil {
+		var err error
+		addrs, err = c.Resolver(req.Context(), host)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	addr, breaker := c.pickAddress(addrs)
+	if breaker == nil {
+		return nil, ErrBreakerOpen
+	}
+
+	client := c.addressClient(addr)
+	return doHTTP(req.Context(), breaker, c.Classifier, func(ctx context.Context) (*http.Response, error) {
+		return client.Do(req.WithContext(ctx))
 	}, c.timeout)
-	return resp, err
+}
+
+// pickAddress chooses, round robin, an address whose breaker is not open.
+func (c *HTTPClient) pickAddress(addrs []string) (string, *Breaker) {
+	if len(addrs) == 0 {
+		return "", nil
+	}
+
+	start := int(atomic.AddUint32(&c.nextAddr, 1) - 1)
+	for i := range addrs {
+		addr := addrs[(start+i)%len(addrs)]
+		cb := c.keyBreaker(addr)
+		if cb.State() != StateOpen {
+			return addr, cb
+		}
+	}
+	return "", nil
+}
+
+// keyBreaker returns the breaker for key, such as a host, creating it from the
+// client's template, or its default breaker, if needed.
+func (c *HTTPClient) keyBreaker(key string) *Breaker {
+	template := c.template
+	if template == nil {
+		template, _ = c.Panel.Get(c.prefix + defaultBreakerName)
+	}
+	return c.Panel.getOrCreate(c.prefix+key, func(string) *Breaker {
+		return template.CloneConfig()
+	})
+}
+
+// addressClient returns a client whose connections are dialed to addr.
+func (c *HTTPClient) addressClient(addr string) *http.Client {
+	c.addrLock.Lock()
+	defer c.addrLock.Unlock()
+
+	if client, ok := c.addrClients[addr]; ok {
+		return client
+	}
+
+	base := c.Client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	transport, ok := base.(*http.Transport)
+	if !ok {
+		return c.Client
+	}
+
+	pinned := transport.Clone()
+	pinned.Proxy = nil
+	dial := pinned.DialContext
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	ip := strings.Trim(addr, "[]")
+	pinned.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+		_, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		return dial(ctx, network, net.JoinHostPort(ip, port))
+	}
+
+	client := *c.Client
+	client.Transport = pinned
+	if c.addrClients == nil {
+		c.addrClients = make(map[string]*http.Client)
+	}
+	c.addrClients[addr] = &client
+	return &client
 }
 
 func (c *HTTPClient) breakerLookup(val interface{}) *Breaker {
 	if c.BreakerLookup != nil {
 		return c.BreakerLookup(c, val)
 	}
-	cb, _ := c.Panel.Get(defaultBreakerName)
+	cb, _ := c.Panel.Get(c.prefix + defaultBreakerName)
 	return cb
 }
 
@@ -0,0 +1,225 @@
+package circuit
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+func TestHostBasedHTTPClientResolver(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	backendURL := "http://backend.test:" + serverURL.Port() + "/"
+
+	// A long backoff keeps the dead backend's breaker from retrying while
+	// the test runs.
+	template := NewBreakerWithOptions(&Options{
+		ShouldTrip: ThresholdTripFunc(1),
+		BackOff:    &backoff.ConstantBackOff{Interval: time.Minute},
+	})
+	client := NewHostBasedHTTPClientWithBreaker(template, 0, nil)
+	client.Resolver = func(ctx context.Context, host string) ([]string, error) {
+		// Nothing listens on 127.0.0.2, so its breaker trips.
+		return []string{"127.0.0.2", "127.0.0.1"}, nil
+	}
+
+	if _, err := client.Get(backendURL); err == nil {
+		t.Fatal("expected request to the dead backend to fail")
+	}
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(backendURL)
+		if err != nil {
+			t.Fatalf("expected request to be sent to the live backend, got %v", err)
+		}
+		resp.Body.Close()
+	}
+
+	if cb, _ := client.Panel.Get("127.0.0.2"); !cb.Tripped() {
+		t.Fatal("expected the dead backend's breaker to be tripped")
+	}
+	if cb, _ := client.Panel.Get("127.0.0.1"); cb.Tripped() || cb.Successes() != 3 {
+		t.Fatal("expected the live backend's breaker to have 3 successes")
+	}
+	if _, ok := client.Panel.Get("backend.test"); ok {
+		t.Fatal("expected no breaker to be keyed by host")
+	}
+}
+
+func TestHTTPClientClassifier(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(0, 2, nil)
+	cb, _ := client.Panel.Get(defaultBreakerName)
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the 503 response to be returned, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable || cb.Failures() != 1 {
+		t.Fatalf("expected the 503 response to be a failure, got status %d and %d failures",
+			resp.StatusCode, cb.Failures())
+	}
+
+	status = http.StatusTooManyRequests
+	resp, err = client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected the 429 response to be returned, got %v", err)
+	}
+	resp.Body.Close()
+	if !cb.Tripped() {
+		t.Fatal("expected the 429 response to trip the breaker")
+	}
+
+	cb.Reset()
+	client.Classifier = func(*http.Response) error { return nil }
+	status = http.StatusInternalServerError
+	resp, _ = client.Get(server.URL)
+	resp.Body.Close()
+	if cb.Failures() != 0 || cb.Successes() != 1 {
+		t.Fatalf("expected the classifier to accept the 500 response, got %d failures and %d successes",
+			cb.Failures(), cb.Successes())
+	}
+}
+
+func TestHTTPClientKeyFunc(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Tenant") == "bad" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	client := NewHTTPClient(0, 1, nil)
+	client.KeyFunc = func(req *http.Request) string {
+		return "tenant." + req.Header.Get("X-Tenant")
+	}
+
+	for _, tenant := range []string{"bad", "good"} {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set("X-Tenant", tenant)
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	if cb, ok := client.Panel.Get("tenant.bad"); !ok || !cb.Tripped() {
+		t.Fatal("expected the bad tenant's breaker to be tripped")
+	}
+	if cb, ok := client.Panel.Get("tenant.good"); !ok || cb.Tripped() || cb.Successes() != 1 {
+		t.Fatal("expected the good tenant's breaker to have 1 success")
+	}
+	if cb, _ := client.Panel.Get(defaultBreakerName); cb.Failures() != 0 || cb.Successes() != 0 {
+		t.Fatal("expected the default breaker to be unused")
+	}
+}
+
+func TestHTTPClientContext(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := NewHTTPClient(0, 1, nil)
+	cb, _ := client.Panel.Get(defaultBreakerName)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := client.GetContext(ctx, server.URL)
+		errc <- err
+	}()
+	cancel()
+
+	if err := <-errc; err == nil {
+		t.Fatal("expected the canceled request to fail")
+	}
+	if cb.Failures() != 0 || cb.Tripped() {
+		t.Fatal("expected the canceled request not to be recorded as a failure")
+	}
+}
+
+func TestHostBasedRateHTTPClient(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer failing.Close()
+	working := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer working.Close()
+
+	client := NewHostBasedRateHTTPClient(0, 0.5, 4, time.Minute, 6, nil)
+
+	for i := 0; i < 4; i++ {
+		if resp, err := client.Get(working.URL); err == nil {
+			resp.Body.Close()
+		}
+		client.Get(failing.URL)
+	}
+
+	failingHost, _ := url.Parse(failing.URL)
+	cb, _ := client.Panel.Get(failingHost.Host)
+	if !cb.Tripped() {
+		t.Fatal("expected the failing host's breaker to be tripped")
+	}
+	if bt := cb.counts.bucketTime; bt != 10*time.Second {
+		t.Fatalf("expected host breakers to use the configured window, got bucket time %v", bt)
+	}
+
+	workingHost, _ := url.Parse(working.URL)
+	if cb, _ := client.Panel.Get(workingHost.Host); cb.Tripped() {
+		t.Fatal("expected the working host's breaker to not be tripped")
+	}
+}
+
+func TestHostBasedHTTPClientWithPanel(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	statter := newTestStatter()
+	panel := NewPanel()
+	panel.Statter = statter
+	client := NewHostBasedHTTPClientWithPanel(panel, "http.", NewThresholdBreaker(1), 0, nil)
+
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	serverURL, _ := url.Parse(server.URL)
+	cb, ok := panel.Get("http." + serverURL.Host)
+	if !ok {
+		t.Fatal("expected the host's breaker to be registered in the panel")
+	}
+	if cb.Successes() != 1 {
+		t.Fatalf("expected the host's breaker to have 1 success, got %d", cb.Successes())
+	}
+	if _, ok := panel.Get("http._default"); !ok {
+		t.Fatal("expected the default breaker to be registered in the panel")
+	}
+
+	cb.Trip()
+	time.Sleep(10 * time.Millisecond)
+	if c := statter.Count("circuit.http." + serverURL.Host + ".tripped"); c != 1 {
+		t.Fatalf("expected the panel to count the trip, got %d", c)
+	}
+}
@@ -0,0 +1,47 @@
+package circuit
+
+import (
+	"context"
+	"os/exec"
+	"time"
+)
+
+// CallCommand runs cmd as a call protected by the Breaker. A non-zero exit
+// status, or a failure to start the command, is recorded as a failure. If the
+// command takes longer than timeout to run, its process group is killed, so
+// any children it spawned are stopped as well, and ErrBreakerTimeout is
+// returned. The command is also killed if ctx is done before it exits.
+//
+// cmd must not have been started. On Unix systems CallCommand places the
+// command in a new process group.
+func (cb *Breaker) CallCommand(ctx context.Context, cmd *exec.Cmd, timeout time.Duration) error {
+	return cb.CallContext(ctx, func() error {
+		setProcessGroup(cmd)
+		if err := cmd.Start(); err != nil {
+			return err
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- cmd.Wait()
+		}()
+
+		var expired <-chan time.Time
+		if timeout > 0 {
+			expired = cb.Clock.After(timeout)
+		}
+
+		select {
+		case err := <-done:
+			return err
+		case <-expired:
+			killProcessGroup(cmd)
+			<-done
+			return ErrBreakerTimeout
+		case <-ctx.Done():
+			killProcessGroup(cmd)
+			<-done
+			return ctx.Err()
+		}
+	}, 0)
+}
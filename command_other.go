@@ -0,0 +1,13 @@
+//go:build !unix
+
+package circuit
+
+import "os/exec"
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills only the command itself; process groups are not
+// available on this platform.
+func killProcessGroup(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package circuit
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestCallCommand(t *testing.T) {
+	cb := NewThresholdBreaker(2)
+
+	if err := cb.CallCommand(context.Background(), exec.Command("sh", "-c", "exit 0"), 0); err != nil {
+		t.Fatalf("expected command to succeed, got %v", err)
+	}
+
+	err := cb.CallCommand(context.Background(), exec.Command("sh", "-c", "exit 3"), 0)
+	if _, ok := err.(*exec.ExitError); !ok {
+		t.Fatalf("expected an exit error, got %v", err)
+	}
+	if f := cb.Failures(); f != 1 {
+		t.Fatalf("expected 1 failure, got %d", f)
+	}
+
+	start := time.Now()
+	err = cb.CallCommand(context.Background(), exec.Command("sh", "-c", "sleep 10 & sleep 10"), 10*time.Millisecond)
+	if err != ErrBreakerTimeout {
+		t.Fatalf("expected a time out, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("expected the process group to be killed, took %v", elapsed)
+	}
+	if !cb.Tripped() {
+		t.Fatal("expected breaker to be tripped")
+	}
+}
@@ -0,0 +1,271 @@
+package circuit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"gopkg.in/yaml.v3"
+)
+
+// Config declares the breakers of a Panel. See NewPanelFromConfig.
+//
+// A config is written in JSON or YAML, for example:
+//
+//	breakers:
+//	  - name: db.users
+//	    type: consecutive
+//	    threshold: 5
+//	  - name: http.search
+//	    type: rate
+//	    rate: 0.5
+//	    min_samples: 100
+//	    window_time: 30s
+//	    window_buckets: 30
+//	    backoff:
+//	      initial_interval: 1s
+//	      max_interval: 1m
+type Config struct {
+	Breakers []BreakerConfig `json:"breakers"`
+}
+
+// BreakerConfig declares a single breaker. Type selects its TripFunc:
+//
+//	threshold    ThresholdTripFunc(Threshold)
+//	consecutive  ConsecutiveTripFunc(Threshold)
+//	rate         RateTripFunc(Rate, MinSamples)
+//	latency      LatencyTripFunc(Quantile, Latency, MinSamples)
+//
+// An empty Type declares a breaker that never trips on its own. Zero values
+// use the package defaults.
+type BreakerConfig struct {
+	Name          string         `json:"name"`
+	Type          string         `json:"type"`
+	Threshold     int64          `json:"threshold"`
+	Rate          float64        `json:"rate"`
+	MinSamples    int64          `json:"min_samples"`
+	Quantile      float64        `json:"quantile"`
+	Latency       Duration       `json:"latency"`
+	WindowTime    Duration       `json:"window_time"`
+	WindowBuckets int            `json:"window_buckets"`
+	BackOff       *BackOffConfig `json:"backoff"`
+}
+
+// BackOffConfig declares the exponential backoff between a tripped breaker's
+// retries. See backoff.ExponentialBackOff.
+type BackOffConfig struct {
+	InitialInterval     Duration `json:"initial_interval"`
+	MaxInterval         Duration `json:"max_interval"`
+	Multiplier          float64  `json:"multiplier"`
+	RandomizationFactor float64  `json:"randomization_factor"`
+	MaxElapsedTime      Duration `json:"max_elapsed_time"`
+}
+
+// Duration is a time.Duration that is written in configs as a string such as
+// "500ms" or "1m", or as a number of nanoseconds.
+type Duration time.Duration
+
+// UnmarshalJSON parses a duration string or number of nanoseconds.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(data, &n); err != nil {
+			return fmt.Errorf("circuit: invalid duration %s", data)
+		}
+		*d = Duration(n)
+		return nil
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return fmt.Errorf("circuit: invalid duration %q", s)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+// MarshalJSON encodes the duration as a string such as "1m30s".
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// ParseConfig parses a JSON or YAML config and validates it.
+func ParseConfig(data []byte) (*Config, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		// Decode YAML generically and re-encode it, so both formats share
+		// the JSON field names and Duration parsing.
+		var doc interface{}
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("circuit: parsing config: %v", err)
+		}
+		if doc == nil {
+			doc = map[string]interface{}{}
+		}
+		var err error
+		if trimmed, err = json.Marshal(doc); err != nil {
+			return nil, fmt.Errorf("circuit: parsing config: %v", err)
+		}
+	}
+
+	var config Config
+	dec := json.NewDecoder(bytes.NewReader(trimmed))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&config); err != nil {
+		return nil, fmt.Errorf("circuit: parsing config: %v", err)
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, err
+	}
+	return &config, nil
+}
+
+// LoadConfig reads and parses the JSON or YAML config file at path.
+func LoadConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data)
+}
+
+func (c *Config) validate() error {
+	seen := make(map[string]bool, len(c.Breakers))
+	for _, bc := range c.Breakers {
+		if bc.Name == "" {
+			return fmt.Errorf("circuit: config breaker has no name")
+		}
+		if seen[bc.Name] {
+			return fmt.Errorf("circuit: config declares breaker %q twice", bc.Name)
+		}
+		seen[bc.Name] = true
+
+		if _, err := bc.tripFunc(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tripFunc returns the TripFunc selected by the config's Type.
+func (bc BreakerConfig) tripFunc() (TripFunc, error) {
+	switch bc.Type {
+	case "":
+		return nil, nil
+	case "threshold":
+		return ThresholdTripFunc(bc.Threshold), nil
+	case "consecutive":
+		return ConsecutiveTripFunc(bc.Threshold), nil
+	case "rate":
+		return RateTripFunc(bc.Rate, bc.MinSamples), nil
+	case "latency":
+		return LatencyTripFunc(bc.Quantile, time.Duration(bc.Latency), bc.MinSamples), nil
+	}
+	return nil, fmt.Errorf("circuit: breaker %q has unknown type %q", bc.Name, bc.Type)
+}
+
+// options returns the Options for the breaker declared by the config.
+func (bc BreakerConfig) options() Options {
+	shouldTrip, _ := bc.tripFunc()
+	options := Options{
+		Name:           bc.Name,
+		ShouldTrip:     shouldTrip,
+		WindowTime:     time.Duration(bc.WindowTime),
+		WindowBuckets:  bc.WindowBuckets,
+		CheckOnSuccess: bc.Type == "latency",
+	}
+
+	if bc.BackOff != nil {
+		b := backoff.NewExponentialBackOff()
+		b.InitialInterval = defaultInitialBackOffInterval
+		b.MaxElapsedTime = defaultBackoffMaxElapsedTime
+		if bc.BackOff.InitialInterval != 0 {
+			b.InitialInterval = time.Duration(bc.BackOff.InitialInterval)
+		}
+		if bc.BackOff.MaxInterval != 0 {
+			b.MaxInterval = time.Duration(bc.BackOff.MaxInterval)
+		}
+		if bc.BackOff.Multiplier != 0 {
+			b.Multiplier = bc.BackOff.Multiplier
+		}
+		if bc.BackOff.RandomizationFactor != 0 {
+			b.RandomizationFactor = bc.BackOff.RandomizationFactor
+		}
+		if bc.BackOff.MaxElapsedTime != 0 {
+			b.MaxElapsedTime = time.Duration(bc.BackOff.MaxElapsedTime)
+		}
+		b.Reset()
+		options.BackOff = b
+	}
+	return options
+}
+
+// neverTrip is the TripFunc of a breaker declared without a type.
+func neverTrip(cb *Breaker) bool {
+	return false
+}
+
+// NewPanelFromConfig creates a Panel holding the breakers declared by config.
+// The panel can be reconfigured later with Reload.
+func NewPanelFromConfig(config *Config) (*Panel, error) {
+	p := NewPanel()
+	if err := p.Reload(config); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload applies config to the panel. Breakers it declares that the panel
+// does not have are created and added. Breakers whose declaration changed
+// since the last Reload are updated in place with UpdateOptions, keeping
+// their state and counts. Breakers declared by the last Reload but not by
+// config are removed. Breakers added to the panel by other means are left
+// alone unless config declares them. As with UpdateOptions, settings left
+// out of a changed declaration keep their current values, except for the
+// type: a breaker whose declaration has no type never trips.
+//
+// The config is validated before anything is changed.
+func (p *Panel) Reload(config *Config) error {
+	if err := config.validate(); err != nil {
+		return err
+	}
+
+	p.configLock.Lock()
+	defer p.configLock.Unlock()
+
+	declared := make(map[string]BreakerConfig, len(config.Breakers))
+	for _, bc := range config.Breakers {
+		declared[bc.Name] = bc
+
+		cb, ok := p.Get(bc.Name)
+		if !ok {
+			options := bc.options()
+			p.Add(bc.Name, NewBreakerWithOptions(&options))
+			continue
+		}
+
+		if last, ok := p.configured[bc.Name]; ok && reflect.DeepEqual(last, bc) {
+			continue
+		}
+		options := bc.options()
+		if options.ShouldTrip == nil {
+			// UpdateOptions keeps the current TripFunc for a nil one.
+			options.ShouldTrip = neverTrip
+		}
+		cb.UpdateOptions(options)
+	}
+
+	for name := range p.configured {
+		if _, ok := declared[name]; !ok {
+			p.Remove(name)
+		}
+	}
+	p.configured = declared
+	return nil
+}
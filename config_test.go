@@ -0,0 +1,245 @@
+package circuit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+)
+
+const testConfigYAML = `
+breakers:
+  - name: db.users
+    type: consecutive
+    threshold: 2
+  - name: http.search
+    type: rate
+    rate: 0.5
+    min_samples: 10
+    window_time: 30s
+    window_buckets: 30
+    backoff:
+      initial_interval: 1s
+      max_interval: 1m
+`
+
+func TestParseConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(testConfigYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fromJSON, err := ParseConfig([]byte(`{"breakers": [
+		{"name": "db.users", "type": "consecutive", "threshold": 2},
+		{"name": "http.search", "type": "rate", "rate": 0.5, "min_samples": 10,
+		 "window_time": "30s", "window_buckets": 30,
+		 "backoff": {"initial_interval": "1s", "max_interval": 60000000000}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(config.Breakers) != 2 || len(fromJSON.Breakers) != 2 {
+		t.Fatalf("expected 2 breakers, got %d and %d", len(config.Breakers), len(fromJSON.Breakers))
+	}
+	for i := range config.Breakers {
+		yc, jc := config.Breakers[i], fromJSON.Breakers[i]
+		if yc.Name != jc.Name || yc.Type != jc.Type || yc.WindowTime != jc.WindowTime {
+			t.Fatalf("expected YAML and JSON configs to match, got %+v and %+v", yc, jc)
+		}
+	}
+
+	search := config.Breakers[1]
+	if time.Duration(search.WindowTime) != 30*time.Second {
+		t.Fatalf("expected window time of 30s, got %s", time.Duration(search.WindowTime))
+	}
+	if time.Duration(search.BackOff.MaxInterval) != time.Minute {
+		t.Fatalf("expected max interval of 1m, got %s", time.Duration(search.BackOff.MaxInterval))
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	bad := []string{
+		`breakers: [{type: threshold}]`,
+		`breakers: [{name: a}, {name: a}]`,
+		`breakers: [{name: a, type: magic}]`,
+		`breakers: [{name: a, window_time: soon}]`,
+		`breakers: [{name: a, threshhold: 5}]`,
+		`{"breakers": [}`,
+	}
+	for _, data := range bad {
+		if _, err := ParseConfig([]byte(data)); err == nil {
+			t.Errorf("expected an error parsing %q", data)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breakers.yaml")
+	if err := os.WriteFile(path, []byte(testConfigYAML), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Breakers) != 2 {
+		t.Fatalf("expected 2 breakers, got %d", len(config.Breakers))
+	}
+}
+
+func TestNewPanelFromConfig(t *testing.T) {
+	config, err := ParseConfig([]byte(testConfigYAML))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPanelFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, ok := p.Get("db.users")
+	if !ok {
+		t.Fatal("expected db.users to be created")
+	}
+	db.Fail()
+	if db.Tripped() {
+		t.Fatal("expected db.users not to trip after 1 failure")
+	}
+	db.Fail()
+	if !db.Tripped() {
+		t.Fatal("expected db.users to trip after 2 consecutive failures")
+	}
+
+	search, ok := p.Get("http.search")
+	if !ok {
+		t.Fatal("expected http.search to be created")
+	}
+	eb, ok := search.BackOff.(*backoff.ExponentialBackOff)
+	if !ok {
+		t.Fatalf("expected an exponential backoff, got %T", search.BackOff)
+	}
+	if eb.InitialInterval != time.Second || eb.MaxInterval != time.Minute {
+		t.Fatalf("expected backoff of 1s to 1m, got %s to %s", eb.InitialInterval, eb.MaxInterval)
+	}
+	if search.options.WindowTime != 30*time.Second || search.options.WindowBuckets != 30 {
+		t.Fatalf("expected a 30s window of 30 buckets, got %s and %d",
+			search.options.WindowTime, search.options.WindowBuckets)
+	}
+}
+
+func TestPanelReload(t *testing.T) {
+	config, err := ParseConfig([]byte(`
+breakers:
+  - {name: a, type: threshold, threshold: 2}
+  - {name: b, type: threshold, threshold: 2}
+  - {name: c}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewPanelFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := NewBreaker()
+	p.Add("other", other)
+
+	a, _ := p.Get("a")
+	b, _ := p.Get("b")
+	a.Fail()
+	b.Fail()
+
+	config, err = ParseConfig([]byte(`
+breakers:
+  - {name: a, type: threshold, threshold: 2}
+  - {name: b, type: threshold, threshold: 5}
+  - {name: d, type: consecutive, threshold: 1}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reload(config); err != nil {
+		t.Fatal(err)
+	}
+
+	if cb, _ := p.Get("a"); cb != a {
+		t.Fatal("expected unchanged breaker a to be kept")
+	}
+	if cb, _ := p.Get("b"); cb != b {
+		t.Fatal("expected changed breaker b to be updated in place")
+	}
+
+	a.Fail()
+	if !a.Tripped() {
+		t.Fatal("expected a to keep its threshold of 2")
+	}
+	b.Fail()
+	if b.Tripped() {
+		t.Fatal("expected b to use its new threshold of 5")
+	}
+	if b.Failures() != 2 {
+		t.Fatalf("expected b to keep its counts, got %d failures", b.Failures())
+	}
+
+	if _, ok := p.Get("c"); ok {
+		t.Fatal("expected undeclared breaker c to be removed")
+	}
+	if _, ok := p.Get("d"); !ok {
+		t.Fatal("expected new breaker d to be added")
+	}
+	if cb, ok := p.Get("other"); !ok || cb != other {
+		t.Fatal("expected breaker added outside the config to be kept")
+	}
+
+	if err := p.Reload(&Config{Breakers: []BreakerConfig{{Name: "a", Type: "nope"}}}); err == nil {
+		t.Fatal("expected an invalid config to be rejected")
+	}
+	if _, ok := p.Get("d"); !ok {
+		t.Fatal("expected an invalid config to change nothing")
+	}
+}
+
+func TestPanelReloadType(t *testing.T) {
+	config, err := ParseConfig([]byte(`breakers: [{name: a, type: threshold, threshold: 1}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := NewPanelFromConfig(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := p.Get("a")
+
+	config, err = ParseConfig([]byte(`breakers: [{name: a, type: latency, quantile: 0.5, latency: 10ms, min_samples: 2}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reload(config); err != nil {
+		t.Fatal(err)
+	}
+	a.SuccessWithDuration(time.Second)
+	a.SuccessWithDuration(time.Second)
+	if !a.Tripped() {
+		t.Fatal("expected slow successes to trip a breaker changed to the latency type")
+	}
+
+	a.Reset()
+	config, err = ParseConfig([]byte(`breakers: [{name: a}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Reload(config); err != nil {
+		t.Fatal(err)
+	}
+	a.FailWithDuration(time.Second)
+	a.SuccessWithDuration(time.Second)
+	if a.Tripped() {
+		t.Fatal("expected a breaker changed to no type to never trip")
+	}
+}
@@ -0,0 +1,131 @@
+package circuit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultConsumerPollInterval is how often a ConsumerController checks whether
+// an open breaker has become ready to retry.
+var DefaultConsumerPollInterval = time.Second
+
+// Consumer is implemented by message consumers, such as Kafka, SQS or AMQP
+// consumers, that can temporarily stop pulling work.
+type Consumer interface {
+	Pause() error
+	Resume() error
+}
+
+// ConsumerController pauses a Consumer while a breaker is open, so that it
+// stops pulling messages it would only fail and retry. Once the breaker is
+// ready to retry, the consumer is resumed so that a message can be used to
+// probe the dependency; it is paused again if the breaker stays open and left
+// running once the breaker resets.
+type ConsumerController struct {
+	breaker  *Breaker
+	consumer Consumer
+	onError  func(error)
+	events   *Subscription
+	paused   bool
+	stopped  bool
+	lock     sync.Mutex
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewConsumerController starts controlling consumer with the breaker registered
+// in the panel under name. An error is returned if no such breaker exists.
+// If onError is not nil, it is called with errors returned by the consumer's
+// Pause and Resume methods; the controller tries again on the next event or
+// poll.
+func NewConsumerController(p *Panel, name string, consumer Consumer, onError func(error)) (*ConsumerController, error) {
+	cb, ok := p.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("circuit: no breaker named %q", name)
+	}
+
+	c := &ConsumerController{
+		breaker:  cb,
+		consumer: consumer,
+		onError:  onError,
+		events:   cb.NewSubscription(context.Background(), 1), // events only wake the loop up
+		stop:     make(chan struct{}),
+	}
+
+	ticker := cb.Clock.Ticker(DefaultConsumerPollInterval)
+	c.update()
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case _, ok := <-c.events.Events():
+				if !ok {
+					return
+				}
+			case <-ticker.C:
+			case <-c.stop:
+				return
+			}
+			c.update()
+		}
+	}()
+
+	return c, nil
+}
+
+// Paused returns true if the controller has paused the consumer.
+func (c *ConsumerController) Paused() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.paused
+}
+
+// Stop stops controlling the consumer. If the consumer is paused, it is
+// resumed.
+func (c *ConsumerController) Stop() {
+	c.stopOnce.Do(func() {
+		close(c.stop)
+		c.events.Unsubscribe()
+
+		c.lock.Lock()
+		defer c.lock.Unlock()
+		c.stopped = true
+		if c.paused {
+			c.setPaused(false)
+		}
+	})
+}
+
+// update pauses or resumes the consumer to match the state of the breaker.
+// It does nothing once the controller is stopped, so an update racing with
+// Stop can not pause the consumer again.
+func (c *ConsumerController) update() {
+	pause := c.breaker.State() == StateOpen
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.stopped && pause != c.paused {
+		c.setPaused(pause)
+	}
+}
+
+// setPaused assumes that the caller has locked c.lock.
+func (c *ConsumerController) setPaused(pause bool) {
+	var err error
+	if pause {
+		err = c.consumer.Pause()
+	} else {
+		err = c.consumer.Resume()
+	}
+
+	if err != nil {
+		if c.onError != nil {
+			c.onError(err)
+		}
+		return
+	}
+	c.paused = pause
+}
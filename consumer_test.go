@@ -0,0 +1,147 @@
+package circuit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/clock"
+)
+
+type testConsumer struct {
+	calls chan string
+}
+
+func (c *testConsumer) Pause() error {
+	c.calls <- "pause"
+	return nil
+}
+
+func (c *testConsumer) Resume() error {
+	c.calls <- "resume"
+	return nil
+}
+
+func (c *testConsumer) expect(t *testing.T, call string) {
+	select {
+	case got := <-c.calls:
+		if got != call {
+			t.Fatalf("expected consumer %s, got %s", call, got)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("expected consumer %s", call)
+	}
+}
+
+func TestConsumerController(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreaker()
+	cb.Clock = c
+	p := NewPanel()
+	p.Add("queue", cb)
+
+	consumer := &testConsumer{calls: make(chan string, 10)}
+	controller, err := NewConsumerController(p, "queue", consumer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer controller.Stop()
+
+	cb.Trip()
+	consumer.expect(t, "pause")
+
+	c.Add(cb.nextBackOff + DefaultConsumerPollInterval)
+	consumer.expect(t, "resume")
+
+	cb.Fail()
+	consumer.expect(t, "pause")
+
+	cb.Reset()
+	consumer.expect(t, "resume")
+
+	if controller.Paused() {
+		t.Fatal("expected consumer to be running")
+	}
+}
+
+type failingConsumer struct{}
+
+func (failingConsumer) Pause() error {
+	return errors.New("pause failed")
+}
+
+func (failingConsumer) Resume() error {
+	return nil
+}
+
+func TestConsumerControllerOnError(t *testing.T) {
+	cb := NewBreaker()
+	p := NewPanel()
+	p.Add("queue", cb)
+	cb.Trip()
+
+	errs := make(chan error, 10)
+	controller, err := NewConsumerController(p, "queue", failingConsumer{}, func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer controller.Stop()
+
+	select {
+	case <-errs:
+	default:
+		t.Fatal("expected the first update's error to be reported")
+	}
+	if controller.Paused() {
+		t.Fatal("expected a consumer that failed to pause not to be paused")
+	}
+
+	cb.Fail()
+	select {
+	case <-errs:
+	case <-time.After(time.Second):
+		t.Fatal("expected the controller to try pausing again")
+	}
+}
+
+func TestConsumerControllerMissingBreaker(t *testing.T) {
+	if _, err := NewConsumerController(NewPanel(), "missing", &testConsumer{}, nil); err == nil {
+		t.Fatal("expected an error for a missing breaker")
+	}
+}
+
+func TestConsumerControllerStop(t *testing.T) {
+	cb := NewBreaker()
+	p := NewPanel()
+	p.Add("queue", cb)
+	subscribers := len(cb.subscribers.load())
+
+	consumer := &testConsumer{calls: make(chan string, 10)}
+	controller, err := NewConsumerController(p, "queue", consumer, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cb.Trip()
+	consumer.expect(t, "pause")
+	controller.Stop()
+	consumer.expect(t, "resume")
+
+	if n := len(cb.subscribers.load()); n != subscribers {
+		t.Fatalf("expected the controller to unsubscribe from the breaker, got %d subscribers", n-subscribers)
+	}
+
+	// An update still running when Stop was called must not pause the
+	// consumer again.
+	controller.update()
+	select {
+	case call := <-consumer.calls:
+		t.Fatalf("expected no calls to the consumer after Stop, got %s", call)
+	default:
+	}
+}
@@ -0,0 +1,119 @@
+package circuit
+
+import "fmt"
+
+// dependency is an edge from a breaker to one of the breakers it depends on.
+type dependency struct {
+	name   string
+	weight int
+}
+
+// DependsOn declares that the breaker registered as parent depends on the one
+// registered as child, for example p.DependsOn("search", "elasticsearch", 5).
+// Whenever child trips, weight failures are recorded on parent, so trouble in
+// a dependency pushes its dependents toward tripping and trips can cascade up
+// the graph. The failures carry a "dependency" metadata key naming child.
+//
+// An error is returned if either breaker is not in the panel or if the
+// dependency would create a cycle.
+func (p *Panel) DependsOn(parent, child string, weight int) error {
+	p.panelLock.Lock()
+	defer p.panelLock.Unlock()
+
+	if _, ok := p.Circuits[parent]; !ok {
+		return fmt.Errorf("circuit: no breaker named %q", parent)
+	}
+	if _, ok := p.Circuits[child]; !ok {
+		return fmt.Errorf("circuit: no breaker named %q", child)
+	}
+	if parent == child || p.dependsOn(child, parent) {
+		return fmt.Errorf("circuit: %q depending on %q would create a cycle", parent, child)
+	}
+
+	deps := p.dependencies[parent]
+	for i, dep := range deps {
+		if dep.name == child {
+			deps[i].weight = weight
+			return nil
+		}
+	}
+	p.dependencies[parent] = append(deps, dependency{name: child, weight: weight})
+	return nil
+}
+
+// Dependencies returns the names of the breakers that name directly depends on.
+func (p *Panel) Dependencies(name string) []string {
+	p.panelLock.RLock()
+	defer p.panelLock.RUnlock()
+
+	var names []string
+	for _, dep := range p.dependencies[name] {
+		names = append(names, dep.name)
+	}
+	return names
+}
+
+// Healthy returns true if neither the breaker registered as name nor any of
+// the breakers it transitively depends on are tripped.
+func (p *Panel) Healthy(name string) bool {
+	p.panelLock.RLock()
+	defer p.panelLock.RUnlock()
+	return p.healthy(name, make(map[string]bool))
+}
+
+// healthy assumes that the caller has locked the panelLock.
+func (p *Panel) healthy(name string, seen map[string]bool) bool {
+	if seen[name] {
+		return true
+	}
+	seen[name] = true
+
+	if cb, ok := p.Circuits[name]; ok && cb.Tripped() {
+		return false
+	}
+	for _, dep := range p.dependencies[name] {
+		if !p.healthy(dep.name, seen) {
+			return false
+		}
+	}
+	return true
+}
+
+// dependsOn returns true if name transitively depends on target. It assumes
+// that the caller has locked the panelLock.
+func (p *Panel) dependsOn(name, target string) bool {
+	for _, dep := range p.dependencies[name] {
+		if dep.name == target || p.dependsOn(dep.name, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// propagateTrip records weighted failures on the breakers that depend on the
+// tripped breaker name. It is called once per trip, when the breaker opens;
+// tripping an already tripped breaker again does not propagate.
+func (p *Panel) propagateTrip(name string) {
+	type parent struct {
+		cb     *Breaker
+		weight int
+	}
+
+	p.panelLock.RLock()
+	var parents []parent
+	for parentName, deps := range p.dependencies {
+		for _, dep := range deps {
+			if dep.name == name {
+				parents = append(parents, parent{p.Circuits[parentName], dep.weight})
+			}
+		}
+	}
+	p.panelLock.RUnlock()
+
+	c := &cause{metadata: map[string]string{"dependency": name}}
+	for _, parent := range parents {
+		for i := 0; i < parent.weight; i++ {
+			parent.cb.fail(noDuration, c)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPanelDependencies(t *testing.T) {
+	p := NewPanel()
+	search := NewThresholdBreaker(5)
+	es := NewThresholdBreaker(1)
+	disk := NewBreaker()
+	p.Add("search", search)
+	p.Add("elasticsearch", es)
+	p.Add("disk", disk)
+
+	if err := p.DependsOn("search", "elasticsearch", 5); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DependsOn("elasticsearch", "disk", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.DependsOn("disk", "search", 1); err == nil {
+		t.Fatal("expected a cycle to be rejected")
+	}
+	if err := p.DependsOn("search", "missing", 1); err == nil {
+		t.Fatal("expected an unknown breaker to be rejected")
+	}
+
+	if deps := p.Dependencies("search"); len(deps) != 1 || deps[0] != "elasticsearch" {
+		t.Fatalf("expected search to depend on elasticsearch, got %v", deps)
+	}
+	if !p.Healthy("search") {
+		t.Fatal("expected search to be healthy")
+	}
+
+	disk.Trip()
+	if p.Healthy("search") {
+		t.Fatal("expected search to be unhealthy when a transitive dependency is tripped")
+	}
+
+	// disk tripping fails elasticsearch once, tripping it, which in turn
+	// records 5 failures on search.
+	if !es.Tripped() || !search.Tripped() {
+		t.Fatal("expected the trip to cascade to search")
+	}
+}
+
+func TestPanelDependencyRetrip(t *testing.T) {
+	p := NewPanel()
+	parent := NewThresholdBreaker(10)
+	child := NewBreaker()
+	p.Add("parent", parent)
+	p.Add("child", child)
+	if err := p.DependsOn("parent", "child", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	child.Trip()
+	child.Trip()
+	child.Reset()
+	child.Trip()
+
+	if f := parent.Failures(); f != 4 {
+		t.Fatalf("expected only the 2 trips that opened the child to propagate, got %d failures", f)
+	}
+}
+
+// slowStatter takes a millisecond to send each stat, so a panel using it falls
+// behind a busy breaker's events.
+type slowStatter struct {
+	noopStatter
+}
+
+func (s *slowStatter) Counter(sampleRate float32, bucket string, n ...int) {
+	time.Sleep(time.Millisecond)
+}
+
+func TestPanelDependencySlowStatter(t *testing.T) {
+	p := NewPanel()
+	p.Statter = &slowStatter{}
+	parent := NewBreaker()
+	child := NewBreaker()
+	p.Add("parent", parent)
+	p.Add("child", child)
+	if err := p.DependsOn("parent", "child", 5); err != nil {
+		t.Fatal(err)
+	}
+
+	child.Trip()
+	for i := 0; i < 300; i++ {
+		child.Fail()
+	}
+	if f := parent.Failures(); f != 5 {
+		t.Fatalf("expected the trip to propagate 5 failures however far behind the stats are, got %d", f)
+	}
+}
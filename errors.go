@@ -0,0 +1,79 @@
+package circuit
+
+import "errors"
+
+// ErrorCode is a machine readable code identifying why the breaker rejected or
+// failed a call.
+type ErrorCode string
+
+const (
+	// CodeOpen is used when a call is rejected because the breaker is open.
+	CodeOpen ErrorCode = "OPEN"
+
+	// CodeTimeout is used when a call takes longer than its time out.
+	CodeTimeout ErrorCode = "TIMEOUT"
+
+	// CodeConcurrency is used when a call is rejected because too many calls
+	// are already in flight.
+	CodeConcurrency ErrorCode = "CONCURRENCY"
+
+	// CodeRateLimited is used when a call is rejected because of a rate limit.
+	// The breaker does not limit rates itself; the code is for rate limiters
+	// used alongside it, so their rejections are mapped the same way.
+	CodeRateLimited ErrorCode = "RATE_LIMITED"
+)
+
+// Error is the type of the errors produced by the breaker itself, such as
+// ErrBreakerOpen and ErrBreakerTimeout. Errors returned by the called function
+// are passed through unchanged.
+type Error struct {
+	Code    ErrorCode
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Code returns the ErrorCode of err. ok is false if err was not produced by
+// the breaker.
+func Code(err error) (code ErrorCode, ok bool) {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code, true
+	}
+	return "", false
+}
+
+// IsBreakerError returns true if err was produced by the breaker rather than
+// by the called function.
+func IsBreakerError(err error) bool {
+	_, ok := Code(err)
+	return ok
+}
+
+// IsOpen returns true if err is a rejection because the breaker is open.
+func IsOpen(err error) bool {
+	return hasCode(err, CodeOpen)
+}
+
+// IsTimeout returns true if err is a time out of the called function.
+func IsTimeout(err error) bool {
+	return hasCode(err, CodeTimeout)
+}
+
+// IsTooManyRequests returns true if err is a rejection because too many calls
+// are in flight.
+func IsTooManyRequests(err error) bool {
+	return hasCode(err, CodeConcurrency)
+}
+
+// IsRateLimited returns true if err is a rejection because of a rate limit.
+func IsRateLimited(err error) bool {
+	return hasCode(err, CodeRateLimited)
+}
+
+func hasCode(err error, code ErrorCode) bool {
+	c, ok := Code(err)
+	return ok && c == code
+}
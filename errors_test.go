@@ -0,0 +1,40 @@
+package circuit
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestErrorCodes(t *testing.T) {
+	if code, ok := Code(ErrBreakerOpen); !ok || code != CodeOpen {
+		t.Fatalf("expected ErrBreakerOpen to have code OPEN, got %q", code)
+	}
+
+	wrapped := fmt.Errorf("calling payments: %w", ErrBreakerTimeout)
+	if !IsTimeout(wrapped) {
+		t.Fatal("expected wrapped ErrBreakerTimeout to be a time out")
+	}
+	if IsOpen(wrapped) {
+		t.Fatal("expected wrapped ErrBreakerTimeout to not be open")
+	}
+
+	limited := &Error{Code: CodeRateLimited, Message: "rate limited"}
+	if !IsRateLimited(fmt.Errorf("calling payments: %w", limited)) || IsTooManyRequests(limited) {
+		t.Fatal("expected a wrapped rate limit rejection to be rate limited only")
+	}
+
+	if IsBreakerError(errors.New("service error")) {
+		t.Fatal("expected a service error to not be a breaker error")
+	}
+}
+
+func TestRejectionIsTyped(t *testing.T) {
+	cb := NewBreaker()
+	cb.Trip()
+
+	err := cb.Call(func() error { return nil }, 0)
+	if !IsOpen(err) {
+		t.Fatalf("expected an open rejection, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package circuit
+
+import "sync"
+
+// DefaultFlapSamples is the default number of samples a breaker's Flapper
+// keeps.
+var DefaultFlapSamples = 20
+
+// Flapper detects flapping: a breaker that keeps tripping and resetting
+// instead of settling in one state. It keeps the breaker's state after each of
+// its most recent outcomes and measures how often consecutive samples differ.
+type Flapper struct {
+	samples   []bool
+	next      int
+	count     int
+	threshold float64
+	lock      sync.Mutex
+}
+
+// NewFlapper creates a Flapper that keeps samples samples and considers the
+// breaker to be flapping when the fraction of them that changed state reaches
+// threshold.
+func NewFlapper(samples int, threshold float64) *Flapper {
+	if samples < 2 {
+		samples = 2
+	}
+	return &Flapper{samples: make([]bool, samples), threshold: threshold}
+}
+
+// Record adds a sample of the breaker's state.
+func (f *Flapper) Record(tripped bool) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.samples[f.next] = tripped
+	f.next = (f.next + 1) % len(f.samples)
+	if f.count < len(f.samples) {
+		f.count++
+	}
+}
+
+// Rate returns the fraction of consecutive samples that differ, from 0 for a
+// breaker that stayed in one state to 1 for one that changed state every time.
+// Until the Flapper has all of its samples, the missing ones count as
+// unchanged.
+func (f *Flapper) Rate() float64 {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.count < 2 {
+		return 0
+	}
+
+	oldest := (f.next - f.count + len(f.samples)) % len(f.samples)
+	changes := 0
+	for i := 1; i < f.count; i++ {
+		prev := f.samples[(oldest+i-1)%len(f.samples)]
+		cur := f.samples[(oldest+i)%len(f.samples)]
+		if prev != cur {
+			changes++
+		}
+	}
+	return float64(changes) / float64(len(f.samples)-1)
+}
+
+// Flapping returns true if the rate of state changes has reached the
+// Flapper's threshold.
+func (f *Flapper) Flapping() bool {
+	return f.Rate() >= f.threshold
+}
@@ -0,0 +1,68 @@
+package circuit
+
+import (
+	"testing"
+
+	"github.com/facebookgo/clock"
+)
+
+func TestFlapper(t *testing.T) {
+	f := NewFlapper(20, 0.2)
+	for i := 0; i < 20; i++ {
+		f.Record(false)
+	}
+	if f.Flapping() {
+		t.Fatal("expected a steady breaker to not be flapping")
+	}
+
+	for i := 0; i < 4; i++ {
+		f.Record(i%2 == 0)
+	}
+	if r := f.Rate(); r != 4.0/19 {
+		t.Fatalf("expected a rate of 4/19, got %f", r)
+	}
+	if !f.Flapping() {
+		t.Fatal("expected the breaker to be flapping")
+	}
+
+	for i := 0; i < 20; i++ {
+		f.Record(false)
+	}
+	if f.Flapping() {
+		t.Fatal("expected the breaker to settle")
+	}
+}
+
+func TestBreakerFlapping(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{
+		Clock:                 c,
+		ShouldTrip:            ThresholdTripFunc(1),
+		FlapSamples:           4,
+		FlapThreshold:         0.5,
+		FlapBackOffMultiplier: 10,
+	})
+
+	cb.Success()
+	cb.Fail()
+	if cb.Flapping() {
+		t.Fatal("expected one trip to not be flapping")
+	}
+
+	c.Add(cb.nextBackOff + 1)
+	cb.Ready()
+	cb.Success()
+	cb.Fail()
+	if !cb.Flapping() {
+		t.Fatal("expected the breaker to be flapping")
+	}
+
+	c.Add(cb.nextBackOff + 1)
+	if cb.Ready() {
+		t.Fatal("expected a flapping breaker to extend its backoff")
+	}
+	c.Add(10 * cb.nextBackOff)
+	if !cb.Ready() {
+		t.Fatal("expected the breaker to retry after the extended backoff")
+	}
+}
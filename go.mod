@@ -3,7 +3,28 @@ module github.com/rubyist/circuitbreaker
 go 1.21.6
 
 require (
-	github.com/cenkalti/backoff v2.2.1+incompatible // indirect
-	github.com/facebookgo/clock v0.0.0-20150410010913-600d898af40a // indirect
-	github.com/peterbourgon/g2s v0.0.0-20170223122336-d4e7ad98afea // indirect
+	github.com/cenkalti/backoff v2.2.1+incompatible
+	github.com/facebookgo/clock v0.0.0-20150410010913-600d898af40a
+	github.com/prometheus/client_golang v1.19.1
+)
+
+require (
+	github.com/alicebob/miniredis/v2 v2.31.1
+	github.com/peterbourgon/g2s v0.0.0-20170223122336-d4e7ad98afea
+	github.com/redis/go-redis/v9 v9.5.3
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/beorn7/perks v1.0.1 // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/prometheus/client_model v0.5.0 // indirect
+	github.com/prometheus/common v0.48.0 // indirect
+	github.com/prometheus/procfs v0.12.0 // indirect
+	github.com/yuin/gopher-lua v1.1.0 // indirect
+	golang.org/x/sys v0.17.0 // indirect
+	google.golang.org/protobuf v1.33.0 // indirect
 )
@@ -0,0 +1,124 @@
+package circuit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"time"
+)
+
+// anonymousOperationName is the breaker name used for GraphQL requests whose
+// operation name can not be determined.
+var anonymousOperationName = "_anonymous"
+
+var graphQLOperationRegexp = regexp.MustCompile(`\b(?:query|mutation|subscription)\s+([_A-Za-z][_0-9A-Za-z]*)`)
+
+// GraphQLClient is a wrapper around http.Client for talking to GraphQL servers.
+// All GraphQL traffic is sent to a single endpoint, so instead of keying
+// breakers by host, the client keys them by the operation name found in the
+// request. One failing operation will not trip the breakers of the others.
+type GraphQLClient struct {
+	Client *http.Client
+	Panel  *Panel
+
+	// Classifier decides whether a response is a failure for the
+	// operation's breaker. If nil, DefaultResponseClassifier is used. The
+	// response is returned either way.
+	Classifier func(*http.Response) error
+
+	timeout   time.Duration
+	threshold int64
+}
+
+// NewGraphQLClient provides a circuit breaker wrapper around http.Client for
+// GraphQL requests. Each operation gets a threshold breaker that trips after
+// threshold failures. Specifying 0 for timeout will give breakers that do not
+// check for time outs.
+func NewGraphQLClient(timeout time.Duration, threshold int64, client *http.Client) *GraphQLClient {
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	return &GraphQLClient{
+		Client:    client,
+		Panel:     NewPanel(),
+		timeout:   timeout,
+		threshold: threshold,
+	}
+}
+
+// Do sends a GraphQL request through the breaker for its operation. The
+// operation name is taken from the operationName field of the request body
+// (or URL query for GET requests) and, failing that, from the query document.
+func (c *GraphQLClient) Do(req *http.Request) (*http.Response, error) {
+	name, err := graphQLOperationName(req)
+	if err != nil {
+		return nil, err
+	}
+
+	return doHTTP(req.Context(), c.Breaker(name), c.Classifier, func(ctx context.Context) (*http.Response, error) {
+		return c.Client.Do(req.WithContext(ctx))
+	}, c.timeout)
+}
+
+// Post sends a GraphQL request body to url. See Do.
+func (c *GraphQLClient) Post(url string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", url, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return c.Do(req)
+}
+
+// Breaker returns the breaker protecting the named operation, creating it if
+// needed.
+func (c *GraphQLClient) Breaker(operationName string) *Breaker {
+	return c.Panel.getOrCreate(operationName, func(string) *Breaker {
+		return NewThresholdBreaker(c.threshold)
+	})
+}
+
+type graphQLParams struct {
+	OperationName string `json:"operationName"`
+	Query         string `json:"query"`
+}
+
+// graphQLOperationName extracts the operation name from req. The request body
+// is read and replaced so that it can still be sent.
+func graphQLOperationName(req *http.Request) (string, error) {
+	var params graphQLParams
+
+	if req.Method == "GET" {
+		q := req.URL.Query()
+		params.OperationName = q.Get("operationName")
+		params.Query = q.Get("query")
+	} else if req.Body != nil {
+		body, err := ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return "", err
+		}
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		// Batched requests are keyed by their first operation.
+		var batch []graphQLParams
+		if json.Unmarshal(body, &batch) == nil && len(batch) > 0 {
+			params = batch[0]
+		} else {
+			json.Unmarshal(body, &params)
+		}
+	}
+
+	if params.OperationName != "" {
+		return params.OperationName, nil
+	}
+	if m := graphQLOperationRegexp.FindStringSubmatch(params.Query); m != nil {
+		return m[1], nil
+	}
+	return anonymousOperationName, nil
+}
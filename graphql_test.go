@@ -0,0 +1,68 @@
+package circuit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGraphQLOperationName(t *testing.T) {
+	tests := []struct {
+		method, url, body, name string
+	}{
+		{"POST", "/graphql", `{"operationName":"GetUser","query":"query GetUser { user { id } }"}`, "GetUser"},
+		{"POST", "/graphql", `{"query":"fragment F on User { id } mutation UpdateUser { update { ...F } }"}`, "UpdateUser"},
+		{"POST", "/graphql", `[{"query":"query First { a }"},{"query":"query Second { b }"}]`, "First"},
+		{"POST", "/graphql", `{"query":"{ user { id } }"}`, anonymousOperationName},
+		{"GET", "/graphql?query=query+Search+%7B+s+%7D", "", "Search"},
+	}
+
+	for _, test := range tests {
+		req, _ := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+		name, err := graphQLOperationName(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if name != test.name {
+			t.Errorf("expected operation name %q for %s, got %q", test.name, test.body, name)
+		}
+	}
+}
+
+func TestGraphQLClientPerOperationBreakers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer server.Close()
+
+	client := NewGraphQLClient(0, 1, nil)
+	client.Breaker("Broken").Trip()
+
+	if _, err := client.Post(server.URL, strings.NewReader(`{"query":"query Broken { a }"}`)); err != ErrBreakerOpen {
+		t.Fatalf("expected Broken operation to be rejected, got %v", err)
+	}
+
+	resp, err := client.Post(server.URL, strings.NewReader(`{"query":"query Working { a }"}`))
+	if err != nil {
+		t.Fatalf("expected Working operation to succeed, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestGraphQLClientServerErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	client := NewGraphQLClient(0, 1, nil)
+	resp, err := client.Post(server.URL, strings.NewReader(`{"query":"query Flaky { a }"}`))
+	if err != nil || resp.StatusCode != http.StatusBadGateway {
+		t.Fatalf("expected the 502 response to be returned, got %v", err)
+	}
+	resp.Body.Close()
+	if !client.Breaker("Flaky").Tripped() {
+		t.Fatal("expected the 502 response to trip the operation's breaker")
+	}
+}
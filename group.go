@@ -0,0 +1,45 @@
+package circuit
+
+import "sync"
+
+// Group is a logical circuit made up of member breakers, such as one breaker
+// per replica of a service. Every outcome recorded by a member is also
+// recorded in the group's shared rolling window, and the group's TripFunc
+// makes a shared trip decision over it. Failures spread across replicas
+// therefore still add up to trip the logical circuit, and while the group is
+// tripped none of its members are Ready.
+//
+// Members keep their own counters and may have their own TripFunc, so a
+// single bad replica can still be tripped on its own.
+type Group struct {
+	*Breaker
+
+	members []*Breaker
+	lock    sync.Mutex
+}
+
+// NewGroup creates a Group whose shared breaker is configured by options. The
+// options' ShouldTrip makes the group's trip decision.
+func NewGroup(options *Options) *Group {
+	return &Group{Breaker: NewBreakerWithOptions(options)}
+}
+
+// NewMember creates a breaker configured by options that feeds the group's
+// shared statistics.
+func (g *Group) NewMember(options *Options) *Breaker {
+	cb := NewBreakerWithOptions(options)
+	cb.group = g.Breaker
+
+	g.lock.Lock()
+	g.members = append(g.members, cb)
+	g.lock.Unlock()
+
+	return cb
+}
+
+// Members returns the group's member breakers.
+func (g *Group) Members() []*Breaker {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return append([]*Breaker(nil), g.members...)
+}
@@ -0,0 +1,74 @@
+package circuit
+
+import (
+	"testing"
+
+	"github.com/facebookgo/clock"
+)
+
+func TestGroupSharedTrip(t *testing.T) {
+	g := NewGroup(&Options{ShouldTrip: ThresholdTripFunc(3)})
+	a := g.NewMember(nil)
+	b := g.NewMember(nil)
+	c := g.NewMember(nil)
+
+	a.Fail()
+	b.Fail()
+	if g.Tripped() {
+		t.Fatal("expected group to not be tripped yet")
+	}
+
+	c.Fail()
+	if !g.Tripped() {
+		t.Fatal("expected failures across members to trip the group")
+	}
+	if a.Tripped() || a.Failures() != 1 {
+		t.Fatal("expected members to keep their own state")
+	}
+	if a.Ready() || b.Ready() || c.Ready() {
+		t.Fatal("expected members of a tripped group to not be ready")
+	}
+
+	g.Reset()
+	if !a.Ready() {
+		t.Fatal("expected members to be ready once the group resets")
+	}
+	if n := len(g.Members()); n != 3 {
+		t.Fatalf("expected 3 members, got %d", n)
+	}
+}
+
+func TestGroupMemberTrip(t *testing.T) {
+	g := NewGroup(&Options{ShouldTrip: ThresholdTripFunc(10)})
+	a := g.NewMember(&Options{ShouldTrip: ThresholdTripFunc(1)})
+	b := g.NewMember(nil)
+
+	a.Fail()
+	if !a.Tripped() || g.Tripped() {
+		t.Fatal("expected only the member to be tripped")
+	}
+	if !b.Ready() {
+		t.Fatal("expected other members to be ready")
+	}
+	if g.Failures() != 1 {
+		t.Fatalf("expected the group to record 1 failure, got %d", g.Failures())
+	}
+}
+
+func TestGroupKeepsMemberTrialCall(t *testing.T) {
+	c := clock.NewMock()
+	g := NewGroup(&Options{Clock: c, ShouldTrip: ThresholdTripFunc(10)})
+	a := g.NewMember(&Options{Clock: c, ShouldTrip: ThresholdTripFunc(1)})
+
+	a.Fail()
+	g.Break()
+	c.Add(a.nextBackOff + 1)
+	if a.Ready() {
+		t.Fatal("expected the member of a broken group to not be ready")
+	}
+
+	g.Reset()
+	if !a.Ready() {
+		t.Fatal("expected the member's trial call to be available once the group resets")
+	}
+}
@@ -0,0 +1,104 @@
+package circuit
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Handler returns an http.Handler serving a small JSON API for administering
+// the panel's breakers:
+//
+//	GET  /              list every breaker's statistics
+//	GET  /{name}        one breaker's statistics
+//	POST /{name}/trip   trip the breaker
+//	POST /{name}/break  trip the breaker and prevent it from auto resetting
+//	POST /{name}/reset  reset the breaker
+//
+// Statistics are in the format of Breaker.MarshalJSON. POST requests respond
+// with the breaker's statistics after the change. Mount the handler under a
+// prefix with http.StripPrefix:
+//
+//	http.Handle("/breakers/", http.StripPrefix("/breakers", panel.Handler()))
+//
+// The handler does no authentication; protect it as you would any other
+// administrative endpoint.
+func (p *Panel) Handler() http.Handler {
+	return http.HandlerFunc(p.serveHTTP)
+}
+
+func (p *Panel) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(r.URL.Path, "/")
+	if path == "" {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, http.MethodGet)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+		return
+	}
+
+	if r.Method == http.MethodGet {
+		p.serveBreaker(w, path)
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, http.MethodGet, http.MethodPost)
+		return
+	}
+
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	name, action := path[:i], path[i+1:]
+
+	cb, ok := p.Get(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "no breaker named "+name)
+		return
+	}
+
+	switch action {
+	case "trip":
+		cb.Trip()
+	case "break":
+		cb.Break()
+	case "reset":
+		cb.Reset()
+	default:
+		writeError(w, http.StatusNotFound, "unknown action "+action)
+		return
+	}
+	p.serveBreaker(w, name)
+}
+
+func (p *Panel) serveBreaker(w http.ResponseWriter, name string) {
+	cb, ok := p.Get(name)
+	if !ok {
+		writeError(w, http.StatusNotFound, "no breaker named "+name)
+		return
+	}
+
+	s := cb.Snapshot()
+	s.Name = name
+	writeJSON(w, http.StatusOK, s)
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
+
+func writeError(w http.ResponseWriter, code int, message string) {
+	writeJSON(w, code, struct {
+		Error string `json:"error"`
+	}{message})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
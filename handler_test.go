@@ -0,0 +1,79 @@
+package circuit
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPanelHandler(t *testing.T) {
+	p := NewPanel()
+	cb := NewBreaker()
+	p.Add("payments", cb)
+	server := httptest.NewServer(p.Handler())
+	defer server.Close()
+
+	resp, err := http.Post(server.URL+"/payments/break", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var stats struct {
+		Name   string `json:"name"`
+		State  string `json:"state"`
+		Broken bool   `json:"broken"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || stats.Name != "payments" || stats.State != "open" || !stats.Broken {
+		t.Fatalf("unexpected response %d %+v", resp.StatusCode, stats)
+	}
+	if cb.Ready() {
+		t.Fatal("expected breaker to be broken")
+	}
+
+	resp, err = http.Post(server.URL+"/payments/reset", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if cb.Tripped() {
+		t.Fatal("expected breaker to be reset")
+	}
+
+	resp, err = http.Get(server.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected 200 listing breakers, got %d", resp.StatusCode)
+	}
+}
+
+func TestPanelHandlerErrors(t *testing.T) {
+	p := NewPanel()
+	p.Add("payments", NewBreaker())
+	handler := p.Handler()
+
+	tests := []struct {
+		method, path string
+		code         int
+	}{
+		{"POST", "/missing/trip", http.StatusNotFound},
+		{"POST", "/payments/explode", http.StatusNotFound},
+		{"GET", "/missing", http.StatusNotFound},
+		{"DELETE", "/payments", http.StatusMethodNotAllowed},
+		{"POST", "/", http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(test.method, test.path, nil))
+		if w.Code != test.code {
+			t.Errorf("%s %s: expected %d, got %d", test.method, test.path, test.code, w.Code)
+		}
+	}
+}
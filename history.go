@@ -0,0 +1,81 @@
+package circuit
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+var (
+	// DefaultErrorHistory is the default number of errors a breaker remembers.
+	DefaultErrorHistory = 10
+
+	// DefaultStackInterval is the default minimum time between two stack
+	// captures when Options.CaptureStacks is set.
+	DefaultStackInterval = time.Second
+)
+
+// ErrorRecord is an error returned by a call that the breaker recorded as a
+// failure.
+type ErrorRecord struct {
+	Err  error
+	Time time.Time
+
+	// Stack is the stack trace of the goroutine that made the call. It is
+	// only captured when Options.CaptureStacks is set, and at most once per
+	// Options.StackInterval, so it is nil for most records.
+	Stack []byte
+}
+
+// errorHistory keeps the most recent errors in a ring.
+type errorHistory struct {
+	records       []ErrorRecord
+	next          int
+	full          bool
+	captureStacks bool
+	stackInterval time.Duration
+	lastStack     time.Time
+	lock          sync.Mutex
+}
+
+func newErrorHistory(size int, captureStacks bool, stackInterval time.Duration) *errorHistory {
+	return &errorHistory{
+		records:       make([]ErrorRecord, size),
+		captureStacks: captureStacks,
+		stackInterval: stackInterval,
+	}
+}
+
+// Record adds err to the history, capturing the stack if it is enabled and
+// one has not been captured within the stack interval.
+func (h *errorHistory) Record(err error, now time.Time) {
+	if len(h.records) == 0 {
+		return
+	}
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	record := ErrorRecord{Err: err, Time: now}
+	if h.captureStacks && (h.lastStack.IsZero() || now.Sub(h.lastStack) >= h.stackInterval) {
+		record.Stack = debug.Stack()
+		h.lastStack = now
+	}
+
+	h.records[h.next] = record
+	h.next = (h.next + 1) % len(h.records)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+// Records returns the recorded errors, oldest first.
+func (h *errorHistory) Records() []ErrorRecord {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if !h.full {
+		return append([]ErrorRecord(nil), h.records[:h.next]...)
+	}
+	return append(append([]ErrorRecord(nil), h.records[h.next:]...), h.records[:h.next]...)
+}
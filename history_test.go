@@ -0,0 +1,58 @@
+package circuit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/facebookgo/clock"
+)
+
+func TestErrorHistory(t *testing.T) {
+	h := newErrorHistory(3, false, time.Second)
+	for i := 0; i < 4; i++ {
+		h.Record(fmt.Errorf("error %d", i), time.Now())
+	}
+
+	records := h.Records()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if msg := records[0].Err.Error(); msg != "error 1" {
+		t.Fatalf("expected oldest record to be error 1, got %s", msg)
+	}
+	if records[2].Stack != nil {
+		t.Fatal("expected no stack to be captured")
+	}
+}
+
+func TestBreakerCapturesStacks(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{
+		Clock:         c,
+		CaptureStacks: true,
+		StackInterval: time.Minute,
+	})
+
+	fail := func() error { return errors.New("error") }
+	cb.Call(fail, 0)
+	cb.Call(fail, 0)
+	c.Add(time.Minute)
+	cb.Call(fail, 0)
+
+	records := cb.Errors()
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+	if !strings.Contains(string(records[0].Stack), "TestBreakerCapturesStacks") {
+		t.Fatalf("expected stack to include the call site, got %s", records[0].Stack)
+	}
+	if records[1].Stack != nil {
+		t.Fatal("expected stack capture to be rate limited")
+	}
+	if records[2].Stack == nil {
+		t.Fatal("expected stack to be captured after the interval")
+	}
+}
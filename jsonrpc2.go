@@ -0,0 +1,118 @@
+package circuit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/rpc"
+	"sync"
+)
+
+// jsonrpc2ClientCodec implements rpc.ClientCodec using JSON-RPC 2.0.
+type jsonrpc2ClientCodec struct {
+	dec *json.Decoder
+	enc *json.Encoder
+	c   io.Closer
+
+	resp jsonrpc2Response
+
+	mutex   sync.Mutex
+	pending map[uint64]string
+}
+
+type jsonrpc2Request struct {
+	Version string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	ID      uint64          `json:"id"`
+}
+
+type jsonrpc2Response struct {
+	Version string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result"`
+	Error   *jsonrpc2Error  `json:"error"`
+	ID      *uint64         `json:"id"`
+}
+
+type jsonrpc2Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+func (e *jsonrpc2Error) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// NewJSONRPC2ClientCodec returns a new rpc.ClientCodec using JSON-RPC 2.0 on
+// conn. Arguments that do not encode to a JSON object or array are sent as a
+// single element array, as the specification requires structured params.
+func NewJSONRPC2ClientCodec(conn io.ReadWriteCloser) rpc.ClientCodec {
+	return &jsonrpc2ClientCodec{
+		dec:     json.NewDecoder(conn),
+		enc:     json.NewEncoder(conn),
+		c:       conn,
+		pending: make(map[uint64]string),
+	}
+}
+
+func (c *jsonrpc2ClientCodec) WriteRequest(r *rpc.Request, param interface{}) error {
+	req := jsonrpc2Request{Version: "2.0", Method: r.ServiceMethod, ID: r.Seq}
+	if param != nil {
+		params, err := json.Marshal(param)
+		if err != nil {
+			return err
+		}
+		if len(params) > 0 && params[0] != '{' && params[0] != '[' {
+			params = append(append([]byte{'['}, params...), ']')
+		}
+		if string(params) != "null" {
+			req.Params = params
+		}
+	}
+
+	c.mutex.Lock()
+	c.pending[r.Seq] = r.ServiceMethod
+	c.mutex.Unlock()
+	return c.enc.Encode(&req)
+}
+
+func (c *jsonrpc2ClientCodec) ReadResponseHeader(r *rpc.Response) error {
+	c.resp = jsonrpc2Response{}
+	if err := c.dec.Decode(&c.resp); err != nil {
+		return err
+	}
+	if c.resp.ID == nil {
+		if c.resp.Error != nil {
+			return c.resp.Error
+		}
+		return errors.New("jsonrpc2: response missing id")
+	}
+
+	c.mutex.Lock()
+	r.Seq = *c.resp.ID
+	r.ServiceMethod = c.pending[r.Seq]
+	delete(c.pending, r.Seq)
+	c.mutex.Unlock()
+
+	r.Error = ""
+	if c.resp.Error != nil {
+		r.Error = c.resp.Error.Error()
+		if r.Error == "" {
+			r.Error = "unspecified error"
+		}
+	}
+	return nil
+}
+
+func (c *jsonrpc2ClientCodec) ReadResponseBody(x interface{}) error {
+	if x == nil || c.resp.Result == nil {
+		return nil
+	}
+	return json.Unmarshal(c.resp.Result, x)
+}
+
+func (c *jsonrpc2ClientCodec) Close() error {
+	return c.c.Close()
+}
@@ -0,0 +1,75 @@
+package circuit
+
+import (
+	"context"
+	"log/slog"
+)
+
+// logEvent logs an event with the breaker's Logger, if it has one. Trips and
+// failed half-open trial calls are logged at Warn, other state changes at
+// Info, and failures that do not change the state at Debug.
+func (cb *Breaker) logEvent(event BreakerEvent, from, to State, c *cause) {
+	if cb.logger == nil {
+		return
+	}
+
+	attrs := []slog.Attr{slog.String("breaker", cb.Name), slog.String("state", to.String())}
+	if c != nil && c.err != nil {
+		attrs = append(attrs, slog.Any("error", c.err))
+	}
+
+	level, msg := slog.LevelDebug, ""
+	switch event {
+	case BreakerTripped:
+		level, msg = slog.LevelWarn, "breaker tripped"
+		attrs = append(attrs, slog.Int64("consecutive_failures", cb.ConsecFailures()))
+	case BreakerReset:
+		switch from {
+		case StateClosed:
+			msg = "breaker reset"
+		case StateHalfOpen:
+			level, msg = slog.LevelInfo, "half-open trial call succeeded, breaker reset"
+		default:
+			level, msg = slog.LevelInfo, "breaker reset"
+		}
+	case BreakerReady:
+		if from == StateHalfOpen {
+			return
+		}
+		level, msg = slog.LevelInfo, "breaker half open"
+	case BreakerFail, BreakerTimeout:
+		if from == StateHalfOpen {
+			level, msg = slog.LevelWarn, "half-open trial call failed"
+			break
+		}
+		if event == BreakerTimeout {
+			// The BreakerFail event that follows logs the failure.
+			return
+		}
+		msg = "call failed"
+		attrs = append(attrs, slog.Int64("consecutive_failures", cb.ConsecFailures()))
+	default:
+		return
+	}
+	cb.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// logProbeSuccess logs a successful half-open trial call that did not reset
+// the breaker, because it needs more of them to.
+func (cb *Breaker) logProbeSuccess(successes int64) {
+	if cb.logger == nil {
+		return
+	}
+	cb.logger.LogAttrs(context.Background(), slog.LevelInfo, "half-open trial call succeeded",
+		slog.String("breaker", cb.Name), slog.Int64("successes", successes))
+}
+
+// logDropped logs an event dropped because a subscriber fell behind. It is
+// logged at Debug, since channels from Subscribe are often left unread.
+func (cb *Breaker) logDropped(e ListenerEvent) {
+	if cb.logger == nil {
+		return
+	}
+	cb.logger.LogAttrs(context.Background(), slog.LevelDebug, "breaker event dropped",
+		slog.String("breaker", cb.Name), slog.Int("event", int(e.Event)))
+}
@@ -0,0 +1,88 @@
+package circuit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"github.com/facebookgo/clock"
+)
+
+func logRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var r map[string]interface{}
+		if err := dec.Decode(&r); err != nil {
+			t.Fatal(err)
+		}
+		records = append(records, r)
+	}
+	buf.Reset()
+	return records
+}
+
+func TestBreakerLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{
+		Name:       "db",
+		Clock:      c,
+		ShouldTrip: ThresholdTripFunc(1),
+		Logger:     logger,
+	})
+
+	cb.Call(func() error { return errors.New("boom") }, 0)
+	records := logRecords(t, &buf)
+	if len(records) != 1 {
+		t.Fatalf("expected only the trip to be logged at Info and above, got %v", records)
+	}
+	r := records[0]
+	if r["level"] != "WARN" || r["msg"] != "breaker tripped" {
+		t.Fatalf("expected a trip warning, got %v", r)
+	}
+	if r["breaker"] != "db" || r["state"] != "open" || r["error"] != "boom" || r["consecutive_failures"] != 1.0 {
+		t.Fatalf("expected the trip's details, got %v", r)
+	}
+
+	c.Add(cb.nextBackOff + 1)
+	cb.Call(func() error { return errors.New("still down") }, 0)
+	records = logRecords(t, &buf)
+	if len(records) != 2 || records[0]["msg"] != "breaker half open" || records[1]["msg"] != "half-open trial call failed" {
+		t.Fatalf("expected the breaker to go half open and its trial call to fail, got %v", records)
+	}
+	if records[1]["level"] != "WARN" || records[1]["error"] != "still down" {
+		t.Fatalf("expected the failed trial call's error, got %v", records[1])
+	}
+
+	c.Add(cb.nextBackOff + 1)
+	cb.Call(func() error { return nil }, 0)
+	records = logRecords(t, &buf)
+	if len(records) != 2 || records[1]["msg"] != "half-open trial call succeeded, breaker reset" || records[1]["state"] != "closed" {
+		t.Fatalf("expected the successful trial call to reset the breaker, got %v", records)
+	}
+}
+
+func TestBreakerLoggerDropped(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	cb := NewBreakerWithOptions(&Options{Name: "db", Logger: logger})
+	listener := make(chan ListenerEvent, 1)
+	cb.AddListener(listener)
+
+	cb.Fail()
+	cb.Fail()
+
+	dropped := 0
+	for _, r := range logRecords(t, &buf) {
+		if r["msg"] == "breaker event dropped" {
+			dropped++
+		}
+	}
+	if dropped != 1 {
+		t.Fatalf("expected 1 dropped event to be logged, got %d", dropped)
+	}
+}
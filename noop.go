@@ -0,0 +1,64 @@
+package circuit
+
+import (
+	"context"
+	"time"
+)
+
+// NoOp returns a CircuitBreaker that never trips. Calls are passed straight
+// through to the function, without time outs, and Trip and Break have no
+// effect. Use it as a default, or to disable circuit breaking.
+func NoOp() CircuitBreaker {
+	return noopBreaker{}
+}
+
+// AlwaysOpen returns a CircuitBreaker that is permanently open. Every call is
+// rejected with ErrBreakerOpen and Reset has no effect. Use it as a kill
+// switch for a dependency, or in tests.
+func AlwaysOpen() CircuitBreaker {
+	return openBreaker{}
+}
+
+type noopBreaker struct{}
+
+func (noopBreaker) Call(circuit func() error, timeout time.Duration) error {
+	return circuit()
+}
+
+func (noopBreaker) CallContext(ctx context.Context, circuit func() error, timeout time.Duration) error {
+	return circuit()
+}
+
+func (noopBreaker) Fail()                 {}
+func (noopBreaker) Success()              {}
+func (noopBreaker) Trip()                 {}
+func (noopBreaker) Reset()                {}
+func (noopBreaker) Break()                {}
+func (noopBreaker) Ready() bool           { return true }
+func (noopBreaker) Tripped() bool         { return false }
+func (noopBreaker) Failures() int64       { return 0 }
+func (noopBreaker) ConsecFailures() int64 { return 0 }
+func (noopBreaker) Successes() int64      { return 0 }
+func (noopBreaker) ErrorRate() float64    { return 0 }
+
+type openBreaker struct{}
+
+func (openBreaker) Call(circuit func() error, timeout time.Duration) error {
+	return ErrBreakerOpen
+}
+
+func (openBreaker) CallContext(ctx context.Context, circuit func() error, timeout time.Duration) error {
+	return ErrBreakerOpen
+}
+
+func (openBreaker) Fail()                 {}
+func (openBreaker) Success()              {}
+func (openBreaker) Trip()                 {}
+func (openBreaker) Reset()                {}
+func (openBreaker) Break()                {}
+func (openBreaker) Ready() bool           { return false }
+func (openBreaker) Tripped() bool         { return true }
+func (openBreaker) Failures() int64       { return 0 }
+func (openBreaker) ConsecFailures() int64 { return 0 }
+func (openBreaker) Successes() int64      { return 0 }
+func (openBreaker) ErrorRate() float64    { return 0 }
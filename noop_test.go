@@ -0,0 +1,41 @@
+package circuit
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNoOp(t *testing.T) {
+	cb := NoOp()
+	cb.Break()
+
+	called := false
+	err := cb.Call(func() error {
+		called = true
+		return errors.New("error")
+	}, 0)
+
+	if !called || err == nil || err.Error() != "error" {
+		t.Fatalf("expected call to pass through, got %v", err)
+	}
+	if cb.Tripped() || !cb.Ready() {
+		t.Fatal("expected no-op breaker to never trip")
+	}
+}
+
+func TestAlwaysOpen(t *testing.T) {
+	cb := AlwaysOpen()
+	cb.Reset()
+
+	err := cb.Call(func() error {
+		t.Fatal("expected call to be rejected")
+		return nil
+	}, 0)
+
+	if err != ErrBreakerOpen {
+		t.Fatalf("expected ErrBreakerOpen, got %v", err)
+	}
+	if !cb.Tripped() || cb.Ready() {
+		t.Fatal("expected always open breaker to stay open")
+	}
+}
@@ -1,13 +1,24 @@
 package circuit
 
 import (
+	"context"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 )
 
 var defaultStatsPrefixf = "circuit.%s"
 
+// DefaultMaxTagValues is the default number of distinct values a Panel will
+// emit stats for per breaker and metadata key.
+var DefaultMaxTagValues = 100
+
+// overflowTagValue replaces metadata values beyond a Panel's MaxTagValues.
+var overflowTagValue = "other"
+
+var tagValueReplacer = strings.NewReplacer(".", "_", ":", "_", "|", "_", "@", "_", " ", "_")
+
 // Statter interface provides a way to gather statistics from breakers
 type Statter interface {
 	Counter(sampleRate float32, bucket string, n ...int)
@@ -26,12 +37,29 @@ type Panel struct {
 	Statter      Statter
 	StatsPrefixf string
 
+	// MaxTagValues limits the number of distinct values of each metadata key
+	// that stats are emitted for, per breaker. Further values are counted
+	// under "other".
+	MaxTagValues int
+
 	Circuits map[string]*Breaker
 
-	lastTripTimes  map[string]time.Time
-	tripTimesLock  sync.RWMutex
-	panelLock      sync.RWMutex
-	eventReceivers []chan PanelEvent
+	// Factory creates the breakers for GetOrCreate whose names match no
+	// prefix set with SetFactory.
+	Factory func(name string) *Breaker
+
+	lastTripTimes map[string]time.Time
+	tripTimesLock sync.RWMutex
+	panelLock     sync.RWMutex
+	subscribers   subscriberList
+	tagValues     map[string]map[string]map[string]bool // breaker name -> metadata key -> values seen
+	tagLock       sync.Mutex
+	dependencies  map[string][]dependency
+	tags          map[string]map[string]bool // names of the breakers under each tag
+	subscriptions map[string]*watcher
+	factories     map[string]func(name string) *Breaker
+	configured    map[string]BreakerConfig // declarations applied by Reload
+	configLock    sync.Mutex
 }
 
 // NewPanel creates a new Panel
@@ -40,21 +68,140 @@ func NewPanel() *Panel {
 		Circuits:      make(map[string]*Breaker),
 		Statter:       &noopStatter{},
 		StatsPrefixf:  defaultStatsPrefixf,
-		lastTripTimes: make(map[string]time.Time)}
+		MaxTagValues:  DefaultMaxTagValues,
+		lastTripTimes: make(map[string]time.Time),
+		tagValues:     make(map[string]map[string]map[string]bool),
+		dependencies:  make(map[string][]dependency),
+		tags:          make(map[string]map[string]bool),
+		subscriptions: make(map[string]*watcher),
+		factories:     make(map[string]func(name string) *Breaker)}
 }
 
 // Add sets the name as a reference to the given circuit breaker.
 func (p *Panel) Add(name string, cb *Breaker) {
+	w := p.watch(name, cb)
+
 	p.panelLock.Lock()
 	p.Circuits[name] = cb
+	replaced := p.subscriptions[name]
+	p.subscriptions[name] = w
 	p.panelLock.Unlock()
 
-	events := cb.Subscribe()
+	if replaced != nil {
+		replaced.stop()
+	}
+}
+
+// GetOrCreate returns the breaker registered as name, creating and adding it
+// if there is none. The breaker is created by the factory set with
+// SetFactory for the longest prefix of name, or by Factory if no prefix
+// matches. Without a factory, a breaker from NewBreaker is created, which
+// never trips on its own.
+func (p *Panel) GetOrCreate(name string) CircuitBreaker {
+	return p.getOrCreate(name, nil)
+}
+
+// SetFactory sets the factory used by GetOrCreate for breakers whose names
+// start with prefix, such as "db." or "http.". A nil factory removes it.
+func (p *Panel) SetFactory(prefix string, factory func(name string) *Breaker) {
+	p.panelLock.Lock()
+	defer p.panelLock.Unlock()
+
+	if factory == nil {
+		delete(p.factories, prefix)
+		return
+	}
+	p.factories[prefix] = factory
+}
+
+// getOrCreate is like GetOrCreate, but uses create, when it is not nil, in
+// preference to Factory. Prefix factories still take precedence over it.
+// Factories are called with the panel locked, so they must not use it.
+func (p *Panel) getOrCreate(name string, create func(name string) *Breaker) *Breaker {
+	p.panelLock.RLock()
+	cb, ok := p.Circuits[name]
+	p.panelLock.RUnlock()
+	if ok {
+		return cb
+	}
+
+	p.panelLock.Lock()
+	defer p.panelLock.Unlock()
+
+	if cb, ok := p.Circuits[name]; ok {
+		return cb
+	}
+
+	factory, longest := create, -1
+	for prefix, f := range p.factories {
+		if strings.HasPrefix(name, prefix) && len(prefix) > longest {
+			factory, longest = f, len(prefix)
+		}
+	}
+	if factory == nil {
+		factory = p.Factory
+	}
+
+	if factory != nil {
+		cb = factory(name)
+	}
+	if cb == nil {
+		cb = NewBreaker()
+	}
+	if cb.Name == "" {
+		cb.Name = name
+	}
+
+	p.Circuits[name] = cb
+	p.subscriptions[name] = p.watch(name, cb)
+	return cb
+}
 
+// watcher is how a Panel follows one of its breakers: a subscription to emit
+// stats and PanelEvents from, and a tripHook.
+type watcher struct {
+	cb   *Breaker
+	sub  *Subscription
+	hook *tripHook
+}
+
+func (w *watcher) stop() {
+	w.sub.Unsubscribe()
+	w.cb.removeSubscriber(w.hook)
+}
+
+// tripHook propagates the trips of a Panel's breaker to the breakers that
+// depend on it. The breaker calls it as it trips, so unlike the panel's
+// subscription it never drops a trip when the panel falls behind.
+type tripHook struct {
+	p    *Panel
+	name string
+}
+
+func (h *tripHook) deliver(e ListenerEvent) bool {
+	if e.Event == BreakerTripped && e.opened {
+		h.p.propagateTrip(h.name)
+	}
+	return false
+}
+
+func (h *tripHook) close() {}
+
+// watch subscribes to cb's events to emit stats and PanelEvents for it, and
+// hooks into its trips to propagate them to its dependents.
+func (p *Panel) watch(name string, cb *Breaker) *watcher {
+	hook := &tripHook{p: p, name: name}
+	cb.addSubscriber(hook)
+
+	sub := cb.NewSubscription(context.Background(), 100)
 	go func() {
-		for event := range events {
-			for _, receiver := range p.eventReceivers {
-				receiver <- PanelEvent{name, event}
+		for e := range sub.Events() {
+			event := e.Event
+			e.Name = name
+			for _, s := range p.subscribers.load() {
+				if s.deliver(e) {
+					cb.logDropped(e)
+				}
 			}
 			switch event {
 			case BreakerTripped:
@@ -65,9 +212,155 @@ func (p *Panel) Add(name string, cb *Breaker) {
 				p.breakerFail(name)
 			case BreakerReady:
 				p.breakerReady(name)
+			case BreakerTimeout:
+				p.breakerTimeout(name)
+			}
+			if len(e.Metadata) > 0 {
+				p.breakerTagged(name, event, e.Metadata)
 			}
 		}
 	}()
+	return &watcher{cb: cb, sub: sub, hook: hook}
+}
+
+// Remove removes the breaker registered as name from the panel, along with its
+// dependencies, and closes it. It returns false if there is no such breaker.
+func (p *Panel) Remove(name string) bool {
+	p.panelLock.Lock()
+	cb, ok := p.Circuits[name]
+	if !ok {
+		p.panelLock.Unlock()
+		return false
+	}
+	delete(p.Circuits, name)
+	delete(p.subscriptions, name)
+	delete(p.dependencies, name)
+	p.untag(name)
+	for parent, deps := range p.dependencies {
+		for i, dep := range deps {
+			if dep.name == name {
+				p.dependencies[parent] = append(deps[:i:i], deps[i+1:]...)
+				break
+			}
+		}
+	}
+	p.panelLock.Unlock()
+
+	p.tripTimesLock.Lock()
+	delete(p.lastTripTimes, name)
+	p.tripTimesLock.Unlock()
+
+	p.tagLock.Lock()
+	delete(p.tagValues, name)
+	p.tagLock.Unlock()
+
+	cb.Close()
+	return true
+}
+
+// AddTagged is like Add, but also registers the breaker under tags, see Tag.
+func (p *Panel) AddTagged(name string, cb *Breaker, tags ...string) {
+	p.Add(name, cb)
+	p.Tag(name, tags...)
+}
+
+// Tag registers the breaker named name under tags, such as the backend it
+// talks to, so it can be controlled with the others under a tag by
+// TripTagged, ResetTagged and BreakTagged. A breaker may have any number of
+// tags. The tags are dropped when the breaker is removed.
+func (p *Panel) Tag(name string, tags ...string) {
+	p.panelLock.Lock()
+	defer p.panelLock.Unlock()
+
+	for _, tag := range tags {
+		names, ok := p.tags[tag]
+		if !ok {
+			names = make(map[string]bool)
+			p.tags[tag] = names
+		}
+		names[name] = true
+	}
+}
+
+// Untag removes the breaker named name from tags. Without tags, it is removed
+// from all of them.
+func (p *Panel) Untag(name string, tags ...string) {
+	p.panelLock.Lock()
+	defer p.panelLock.Unlock()
+	p.untag(name, tags...)
+}
+
+// untag is Untag with the panel locked.
+func (p *Panel) untag(name string, tags ...string) {
+	if len(tags) == 0 {
+		for tag := range p.tags {
+			tags = append(tags, tag)
+		}
+	}
+	for _, tag := range tags {
+		if names, ok := p.tags[tag]; ok {
+			delete(names, name)
+			if len(names) == 0 {
+				delete(p.tags, tag)
+			}
+		}
+	}
+}
+
+// Tagged returns the panel's breakers registered under tag, keyed by name.
+func (p *Panel) Tagged(tag string) map[string]*Breaker {
+	p.panelLock.RLock()
+	defer p.panelLock.RUnlock()
+
+	breakers := make(map[string]*Breaker, len(p.tags[tag]))
+	for name := range p.tags[tag] {
+		if cb, ok := p.Circuits[name]; ok {
+			breakers[name] = cb
+		}
+	}
+	return breakers
+}
+
+// TripAll trips every breaker in the panel, returning the number of breakers.
+func (p *Panel) TripAll() int {
+	return applyEach(p.Breakers(), (*Breaker).Trip)
+}
+
+// ResetAll resets every breaker in the panel, including broken ones,
+// returning the number of breakers.
+func (p *Panel) ResetAll() int {
+	return applyEach(p.Breakers(), (*Breaker).Reset)
+}
+
+// BreakAll breaks every breaker in the panel, so they stay open until reset,
+// returning the number of breakers. Use it to force every call to fail fast,
+// for example during maintenance.
+func (p *Panel) BreakAll() int {
+	return applyEach(p.Breakers(), (*Breaker).Break)
+}
+
+// TripTagged trips the breakers registered under tag, returning their number.
+func (p *Panel) TripTagged(tag string) int {
+	return applyEach(p.Tagged(tag), (*Breaker).Trip)
+}
+
+// ResetTagged resets the breakers registered under tag, returning their
+// number.
+func (p *Panel) ResetTagged(tag string) int {
+	return applyEach(p.Tagged(tag), (*Breaker).Reset)
+}
+
+// BreakTagged breaks the breakers registered under tag, returning their
+// number.
+func (p *Panel) BreakTagged(tag string) int {
+	return applyEach(p.Tagged(tag), (*Breaker).Break)
+}
+
+func applyEach(breakers map[string]*Breaker, fn func(*Breaker)) int {
+	for _, cb := range breakers {
+		fn(cb)
+	}
+	return len(breakers)
 }
 
 // Get retrieves a circuit breaker by name.  If no circuit breaker exists, it
@@ -84,26 +377,60 @@ func (p *Panel) Get(name string) (*Breaker, bool) {
 	return NewBreaker(), ok
 }
 
+// Breakers returns a copy of the panel's breakers, keyed by name.
+func (p *Panel) Breakers() map[string]*Breaker {
+	p.panelLock.RLock()
+	defer p.panelLock.RUnlock()
+
+	breakers := make(map[string]*Breaker, len(p.Circuits))
+	for name, cb := range p.Circuits {
+		breakers[name] = cb
+	}
+	return breakers
+}
+
 // Subscribe returns a channel of PanelEvents. Whenever a breaker changes state,
 // the PanelEvent will be sent over the channel. See BreakerEvent for the types of events.
+// The channel is buffered; if it fills up, the oldest event is dropped. Use
+// NewSubscription for the full ListenerEvents.
 func (p *Panel) Subscribe() <-chan PanelEvent {
-	eventReader := make(chan PanelEvent)
-	output := make(chan PanelEvent, 100)
+	s := make(panelEventSubscriber, DefaultSubscriptionBuffer)
+	p.addSubscriber(s)
+	return s
+}
 
-	go func() {
-		for v := range eventReader {
-			select {
-			case output <- v:
-			default:
-				<-output
-				output <- v
-			}
-		}
-	}()
-	p.eventReceivers = append(p.eventReceivers, eventReader)
-	return output
+// NewSubscription subscribes to the events of every breaker in the panel,
+// including breakers added later, as a single stream. Each event's Name is
+// the name the breaker was added as. Otherwise it is like
+// Breaker.NewSubscription.
+func (p *Panel) NewSubscription(ctx context.Context, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+
+	s := &Subscription{source: p, events: make(chan ListenerEvent, buffer)}
+	p.addSubscriber(s)
+	s.unsubscribeWhenDone(ctx)
+	return s
+}
+
+func (p *Panel) addSubscriber(s subscriber) {
+	p.subscribers.add(s)
+}
+
+func (p *Panel) removeSubscriber(s subscriber) bool {
+	return p.subscribers.remove(s)
+}
+
+// panelEventSubscriber delivers PanelEvents for Subscribe.
+type panelEventSubscriber chan PanelEvent
+
+func (s panelEventSubscriber) deliver(e ListenerEvent) bool {
+	return sendDropOldest(s, PanelEvent{e.Name, e.Event})
 }
 
+func (s panelEventSubscriber) close() {}
+
 func (p *Panel) breakerTripped(name string) {
 	p.Statter.Counter(1.0, fmt.Sprintf(p.StatsPrefixf, name)+".tripped", 1)
 	p.tripTimesLock.Lock()
@@ -132,10 +459,61 @@ func (p *Panel) breakerFail(name string) {
 	p.Statter.Counter(1.0, fmt.Sprintf(p.StatsPrefixf, name)+".fail", 1)
 }
 
+func (p *Panel) breakerTimeout(name string) {
+	p.Statter.Counter(1.0, fmt.Sprintf(p.StatsPrefixf, name)+".timeout", 1)
+}
+
 func (p *Panel) breakerReady(name string) {
 	p.Statter.Counter(1.0, fmt.Sprintf(p.StatsPrefixf, name)+".ready", 1)
 }
 
+// breakerTagged emits per-tag counts for fail and trip events caused by calls
+// with metadata, e.g. circuit.payments.operation.GetUser.fail.
+func (p *Panel) breakerTagged(name string, event BreakerEvent, metadata map[string]string) {
+	var suffix string
+	switch event {
+	case BreakerFail:
+		suffix = ".fail"
+	case BreakerTripped:
+		suffix = ".tripped"
+	default:
+		return
+	}
+
+	bucket := fmt.Sprintf(p.StatsPrefixf, name)
+	for key, value := range metadata {
+		key = tagValueReplacer.Replace(key)
+		value = p.tagValue(name, key, tagValueReplacer.Replace(value))
+		p.Statter.Counter(1.0, bucket+"."+key+"."+value+suffix, 1)
+	}
+}
+
+// tagValue returns value, or overflowTagValue if the breaker has already seen
+// MaxTagValues other values for key.
+func (p *Panel) tagValue(name, key, value string) string {
+	p.tagLock.Lock()
+	defer p.tagLock.Unlock()
+
+	keys, ok := p.tagValues[name]
+	if !ok {
+		keys = make(map[string]map[string]bool)
+		p.tagValues[name] = keys
+	}
+	seen, ok := keys[key]
+	if !ok {
+		seen = make(map[string]bool)
+		keys[key] = seen
+	}
+	if seen[value] {
+		return value
+	}
+	if len(seen) >= p.MaxTagValues {
+		return overflowTagValue
+	}
+	seen[value] = true
+	return value
+}
+
 type noopStatter struct {
 }
 
@@ -1,6 +1,8 @@
 package circuit
 
 import (
+	"context"
+	"fmt"
 	"reflect"
 	"sync"
 	"testing"
@@ -36,6 +38,91 @@ func TestPanelAdd(t *testing.T) {
 	}
 }
 
+func TestPanelAddLeavesBreakerNameAlone(t *testing.T) {
+	cb := NewBreaker()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			_ = cb.String()
+		}
+	}()
+
+	p := NewPanel()
+	p.Add("a", cb)
+	<-done
+	if cb.Name != "" {
+		t.Fatalf("expected the breaker in use to keep its name, got %q", cb.Name)
+	}
+
+	if b := p.GetOrCreate("b").(*Breaker); b.Name != "b" {
+		t.Fatalf("expected a created breaker to be named 'b', got %q", b.Name)
+	}
+}
+
+func TestPanelRemove(t *testing.T) {
+	p := NewPanel()
+	a, b := NewBreaker(), NewBreaker()
+	p.Add("a", a)
+	p.Add("b", b)
+	if err := p.DependsOn("b", "a", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.Remove("a") {
+		t.Fatal("expected a to be removed")
+	}
+	if p.Remove("a") {
+		t.Fatal("expected removing a again to return false")
+	}
+	if _, ok := p.Get("a"); ok {
+		t.Fatal("expected a to no longer be in the panel")
+	}
+	if deps := p.Dependencies("b"); len(deps) != 0 {
+		t.Fatalf("expected dependencies on a to be removed, got %v", deps)
+	}
+	if len(a.subscribers.load()) != 0 {
+		t.Fatal("expected the panel's subscription to the removed breaker to end")
+	}
+}
+
+func TestPanelGetOrCreate(t *testing.T) {
+	p := NewPanel()
+	p.Factory = func(name string) *Breaker {
+		return NewThresholdBreaker(10)
+	}
+	p.SetFactory("db.", func(name string) *Breaker {
+		return NewThresholdBreaker(1)
+	})
+	p.SetFactory("db.primary.", func(name string) *Breaker {
+		return NewThresholdBreaker(2)
+	})
+
+	tests := []struct {
+		name     string
+		failures int
+	}{
+		{"http.search", 10},
+		{"db.replica", 1},
+		{"db.primary.users", 2},
+	}
+	for _, test := range tests {
+		cb := p.GetOrCreate(test.name)
+		if p.GetOrCreate(test.name) != cb {
+			t.Fatalf("expected %s to be created once", test.name)
+		}
+		for i := 0; i < test.failures; i++ {
+			cb.Fail()
+		}
+		if !cb.Tripped() {
+			t.Errorf("expected %s to trip after %d failures", test.name, test.failures)
+		}
+		if added, _ := p.Get(test.name); added != cb {
+			t.Errorf("expected %s to be added to the panel", test.name)
+		}
+	}
+}
+
 func TestPanelStats(t *testing.T) {
 	statter := newTestStatter()
 	p := NewPanel()
@@ -111,3 +198,150 @@ func (s *testStatter) Timing(sampleRate float32, bucket string, d ...time.Durati
 }
 
 func (*testStatter) Gauge(sampleRate float32, bucket string, value ...string) {}
+
+func TestPanelTagStats(t *testing.T) {
+	statter := newTestStatter()
+	p := NewPanel()
+	p.Statter = statter
+	p.MaxTagValues = 2
+	rb := NewBreaker()
+	p.Add("breaker", rb)
+
+	fail := func() error { return fmt.Errorf("error") }
+	rb.CallWithOptions(fail, WithMetadata("operation", "GetUser"))
+	rb.CallWithOptions(fail, WithMetadata("operation", "GetUser"))
+	rb.CallWithOptions(fail, WithMetadata("operation", "Get.Order"))
+	rb.CallWithOptions(fail, WithMetadata("operation", "ListUsers"))
+
+	time.Sleep(10 * time.Millisecond)
+
+	if c := statter.Count("circuit.breaker.operation.GetUser.fail"); c != 2 {
+		t.Fatalf("expected GetUser fail count to be 2, got %d", c)
+	}
+	if c := statter.Count("circuit.breaker.operation.Get_Order.fail"); c != 1 {
+		t.Fatalf("expected Get_Order fail count to be 1, got %d", c)
+	}
+	if c := statter.Count("circuit.breaker.operation.other.fail"); c != 1 {
+		t.Fatalf("expected overflow fail count to be 1, got %d", c)
+	}
+	if c := statter.Count("circuit.breaker.fail"); c != 4 {
+		t.Fatalf("expected fail count to be 4, got %d", c)
+	}
+}
+
+func TestPanelNewSubscription(t *testing.T) {
+	p := NewPanel()
+	a := NewBreaker()
+	p.Add("a", a)
+	s := p.NewSubscription(context.Background(), 0)
+	b := NewBreaker()
+	p.Add("b", b)
+
+	a.Trip()
+	b.Trip()
+
+	names := map[string]bool{}
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-s.Events():
+			if e.Event != BreakerTripped {
+				t.Fatalf("expected a trip, got %v", e.Event)
+			}
+			names[e.Name] = true
+		case <-time.After(time.Second):
+			t.Fatal("expected an event from each breaker")
+		}
+	}
+	if !names["a"] || !names["b"] {
+		t.Fatalf("expected events named a and b, got %v", names)
+	}
+
+	s.Unsubscribe()
+	if _, ok := <-s.Events(); ok {
+		t.Fatal("expected the events channel to be closed")
+	}
+	if len(p.subscribers.load()) != 0 {
+		t.Fatal("expected the subscription to be removed from the panel")
+	}
+}
+
+func TestPanelBulkOperations(t *testing.T) {
+	p := NewPanel()
+	a, b := NewBreaker(), NewBreaker()
+	p.Add("a", a)
+	p.Add("b", b)
+
+	if n := p.TripAll(); n != 2 {
+		t.Fatalf("expected 2 breakers to be tripped, got %d", n)
+	}
+	if !a.Tripped() || !b.Tripped() {
+		t.Fatal("expected every breaker to be tripped")
+	}
+
+	p.ResetAll()
+	if a.Tripped() || b.Tripped() {
+		t.Fatal("expected every breaker to be reset")
+	}
+
+	p.BreakAll()
+	if a.state() != StateOpen || b.state() != StateOpen || !a.Tripped() {
+		t.Fatal("expected every breaker to be broken")
+	}
+	p.ResetAll()
+	if a.Tripped() || b.Tripped() {
+		t.Fatal("expected broken breakers to be reset")
+	}
+}
+
+func TestPanelTags(t *testing.T) {
+	p := NewPanel()
+	users, orders, search := NewBreaker(), NewBreaker(), NewBreaker()
+	p.AddTagged("db.users", users, "postgres", "payments")
+	p.AddTagged("db.orders", orders, "postgres")
+	p.Add("http.search", search)
+	p.Tag("missing", "postgres")
+
+	if tagged := p.Tagged("postgres"); len(tagged) != 2 || tagged["db.users"] != users || tagged["db.orders"] != orders {
+		t.Fatalf("expected the postgres breakers, got %v", tagged)
+	}
+
+	if n := p.BreakTagged("postgres"); n != 2 {
+		t.Fatalf("expected 2 breakers to be broken, got %d", n)
+	}
+	if !users.Tripped() || !orders.Tripped() || search.Tripped() {
+		t.Fatal("expected only the postgres breakers to be broken")
+	}
+
+	p.ResetTagged("payments")
+	if users.Tripped() || !orders.Tripped() {
+		t.Fatal("expected only the payments breaker to be reset")
+	}
+
+	p.Untag("db.orders", "postgres")
+	if n := p.TripTagged("postgres"); n != 1 || !users.Tripped() {
+		t.Fatalf("expected only db.users to be tripped, got %d breakers", n)
+	}
+
+	p.Remove("db.users")
+	if n := p.ResetTagged("payments"); n != 0 {
+		t.Fatalf("expected a removed breaker's tags to be dropped, got %d breakers", n)
+	}
+	p.Add("db.users", NewBreaker())
+	if len(p.Tagged("postgres")) != 0 {
+		t.Fatal("expected a re-added breaker to start without tags")
+	}
+}
+
+func TestPanelRemoveKeepsOtherTagValues(t *testing.T) {
+	p := NewPanel()
+	p.MaxTagValues = 1
+	p.Add("db", NewBreaker())
+	p.Add("db.users", NewBreaker())
+
+	p.tagValue("db.users", "operation", "GetUser")
+	p.Remove("db")
+
+	if v := p.tagValue("db.users", "operation", "ListUsers"); v != overflowTagValue {
+		t.Fatalf("expected removing db to keep the values seen by db.users, got %q", v)
+	}
+}
@@ -0,0 +1,100 @@
+package circuit
+
+import (
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"time"
+)
+
+// RPCCaller is the subset of *rpc.Client used by RPCClient. Any client with a
+// net/rpc style Call method may be wrapped.
+type RPCCaller interface {
+	Call(serviceMethod string, args interface{}, reply interface{}) error
+}
+
+// RPCClient is a wrapper around an RPC client that provides circuit breaker
+// capabilities. Each service method is protected by its own breaker, named
+// by the "Service.Method" string, so one failing method does not affect the
+// others.
+type RPCClient struct {
+	Client    RPCCaller
+	Panel     *Panel
+	timeout   time.Duration
+	threshold int64
+}
+
+// NewRPCClient provides a circuit breaker wrapper around an RPC client. Each
+// service method gets a threshold breaker that trips after threshold failures.
+// Specifying 0 for timeout will give breakers that do not check for time outs.
+func NewRPCClient(timeout time.Duration, threshold int64, client RPCCaller) *RPCClient {
+	return &RPCClient{
+		Client:    client,
+		Panel:     NewPanel(),
+		timeout:   timeout,
+		threshold: threshold,
+	}
+}
+
+// DialRPC connects to an RPC server at the specified network address and
+// wraps the resulting client with NewRPCClient.
+func DialRPC(network, address string, timeout time.Duration, threshold int64) (*RPCClient, error) {
+	client, err := rpc.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewRPCClient(timeout, threshold, client), nil
+}
+
+// DialJSONRPC connects to a JSON-RPC 1.0 server at the specified network
+// address and wraps the resulting client with NewRPCClient.
+func DialJSONRPC(network, address string, timeout time.Duration, threshold int64) (*RPCClient, error) {
+	client, err := jsonrpc.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewRPCClient(timeout, threshold, client), nil
+}
+
+// NewJSONRPC2Client wraps conn in a JSON-RPC 2.0 client protected by
+// per-method breakers. See NewRPCClient for the meaning of timeout and
+// threshold.
+func NewJSONRPC2Client(timeout time.Duration, threshold int64, conn io.ReadWriteCloser) *RPCClient {
+	client := rpc.NewClientWithCodec(NewJSONRPC2ClientCodec(conn))
+	return NewRPCClient(timeout, threshold, client)
+}
+
+// DialJSONRPC2 connects to a JSON-RPC 2.0 server at the specified network
+// address and wraps the resulting client with NewJSONRPC2Client.
+func DialJSONRPC2(network, address string, timeout time.Duration, threshold int64) (*RPCClient, error) {
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return NewJSONRPC2Client(timeout, threshold, conn), nil
+}
+
+// Call invokes the named method through the breaker for serviceMethod. If the
+// breaker is open, ErrBreakerOpen is returned and the method is not called.
+func (c *RPCClient) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	breaker := c.Breaker(serviceMethod)
+	return breaker.Call(func() error {
+		return c.Client.Call(serviceMethod, args, reply)
+	}, c.timeout)
+}
+
+// Breaker returns the breaker protecting serviceMethod, creating it if needed.
+func (c *RPCClient) Breaker(serviceMethod string) *Breaker {
+	return c.Panel.getOrCreate(serviceMethod, func(string) *Breaker {
+		return NewThresholdBreaker(c.threshold)
+	})
+}
+
+// Close closes the underlying client if it implements io.Closer.
+func (c *RPCClient) Close() error {
+	if closer, ok := c.Client.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package circuit
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/rpc"
+	"testing"
+)
+
+// RPCArith is exported so net/rpc will register it.
+type RPCArith struct{}
+
+type RPCArgs struct {
+	A, B int
+}
+
+func (RPCArith) Add(args RPCArgs, reply *int) error {
+	*reply = args.A + args.B
+	return nil
+}
+
+func (RPCArith) Fail(args RPCArgs, reply *int) error {
+	return errors.New("failed")
+}
+
+func newTestRPCClient(t *testing.T, threshold int64) *RPCClient {
+	server := rpc.NewServer()
+	if err := server.RegisterName("Arith", RPCArith{}); err != nil {
+		t.Fatal(err)
+	}
+	cli, srv := net.Pipe()
+	go server.ServeConn(srv)
+	return NewRPCClient(0, threshold, rpc.NewClient(cli))
+}
+
+func TestRPCClientPerMethodBreakers(t *testing.T) {
+	client := newTestRPCClient(t, 2)
+	defer client.Close()
+
+	var reply int
+	for i := 0; i < 2; i++ {
+		if err := client.Call("Arith.Fail", RPCArgs{}, &reply); err == nil {
+			t.Fatal("expected Arith.Fail to return an error")
+		}
+	}
+
+	if err := client.Call("Arith.Fail", RPCArgs{}, &reply); err != ErrBreakerOpen {
+		t.Fatalf("expected Arith.Fail breaker to be open, got %v", err)
+	}
+
+	if err := client.Call("Arith.Add", RPCArgs{A: 1, B: 2}, &reply); err != nil {
+		t.Fatalf("expected Arith.Add to succeed, got %v", err)
+	}
+	if reply != 3 {
+		t.Fatalf("expected reply to be 3, got %d", reply)
+	}
+}
+
+func TestJSONRPC2Client(t *testing.T) {
+	cli, srv := net.Pipe()
+	client := NewJSONRPC2Client(0, 1, cli)
+	defer client.Close()
+
+	go func() {
+		dec := json.NewDecoder(srv)
+		enc := json.NewEncoder(srv)
+		for {
+			var req jsonrpc2Request
+			if err := dec.Decode(&req); err != nil {
+				return
+			}
+			resp := map[string]interface{}{"jsonrpc": "2.0", "id": req.ID}
+			if req.Method == "math.add" {
+				var args []int
+				json.Unmarshal(req.Params, &args)
+				resp["result"] = args[0] + args[1]
+			} else {
+				resp["error"] = map[string]interface{}{"code": -32601, "message": "method not found"}
+			}
+			enc.Encode(resp)
+		}
+	}()
+
+	var sum int
+	if err := client.Call("math.add", []int{2, 3}, &sum); err != nil {
+		t.Fatalf("expected math.add to succeed, got %v", err)
+	}
+	if sum != 5 {
+		t.Fatalf("expected sum to be 5, got %d", sum)
+	}
+
+	err := client.Call("math.missing", nil, nil)
+	if _, ok := err.(rpc.ServerError); !ok {
+		t.Fatalf("expected a server error, got %v", err)
+	}
+	if !client.Breaker("math.missing").Tripped() {
+		t.Fatal("expected math.missing breaker to be tripped")
+	}
+	if client.Breaker("math.add").Tripped() {
+		t.Fatal("expected math.add breaker to not be tripped")
+	}
+}
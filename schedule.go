@@ -0,0 +1,75 @@
+package circuit
+
+import "time"
+
+// ScheduleWindow applies a TripFunc during a daily time range, for example
+// stricter thresholds during business hours.
+type ScheduleWindow struct {
+	// Start and End are offsets from midnight. If End is not after Start the
+	// window wraps past midnight, so a 22h to 6h window covers the night.
+	Start time.Duration
+	End   time.Duration
+
+	// Weekdays limits the window to the given days, matched against the day
+	// the window starts on. An empty list matches every day.
+	Weekdays []time.Weekday
+
+	// Location is the time zone Start and End are in. If nil, the time zone
+	// of the breaker's Clock is used.
+	Location *time.Location
+
+	ShouldTrip TripFunc
+}
+
+// contains returns true if t falls within the window.
+func (w ScheduleWindow) contains(t time.Time) bool {
+	if w.Location != nil {
+		t = t.In(w.Location)
+	}
+
+	// The wall clock time, which on days the clocks change differs from the
+	// time elapsed since midnight.
+	offset := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute +
+		time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+	day := t.Weekday()
+
+	if w.End > w.Start {
+		return offset >= w.Start && offset < w.End && w.onDay(day)
+	}
+	if offset >= w.Start {
+		return w.onDay(day)
+	}
+	// Before End, the window started on the previous day.
+	return offset < w.End && w.onDay((day+6)%7)
+}
+
+func (w ScheduleWindow) onDay(day time.Weekday) bool {
+	if len(w.Weekdays) == 0 {
+		return true
+	}
+	for _, d := range w.Weekdays {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// ScheduledTripFunc returns a TripFunc that delegates to the TripFunc of the
+// first window containing the current time of the breaker's Clock, or to
+// fallback outside of all windows. A nil TripFunc never trips. Because the
+// active TripFunc is chosen on every evaluation, the change takes effect
+// exactly at a window's boundary.
+func ScheduledTripFunc(fallback TripFunc, windows ...ScheduleWindow) TripFunc {
+	return func(cb *Breaker) bool {
+		shouldTrip := fallback
+		now := cb.Clock.Now()
+		for _, w := range windows {
+			if w.contains(now) {
+				shouldTrip = w.ShouldTrip
+				break
+			}
+		}
+		return shouldTrip != nil && shouldTrip(cb)
+	}
+}
@@ -0,0 +1,90 @@
+package circuit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/facebookgo/clock"
+)
+
+func TestScheduleWindowContains(t *testing.T) {
+	night := ScheduleWindow{Start: 22 * time.Hour, End: 6 * time.Hour, Location: time.UTC}
+	weekdays := ScheduleWindow{
+		Start:    9 * time.Hour,
+		End:      17 * time.Hour,
+		Weekdays: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Location: time.UTC,
+	}
+
+	tests := []struct {
+		w        ScheduleWindow
+		t        string
+		expected bool
+	}{
+		{night, "2024-05-13T23:00:00Z", true},
+		{night, "2024-05-14T05:59:59Z", true},
+		{night, "2024-05-14T06:00:00Z", false},
+		{night, "2024-05-14T12:00:00Z", false},
+		{weekdays, "2024-05-13T09:00:00Z", true},  // Monday
+		{weekdays, "2024-05-13T17:00:00Z", false}, // Monday
+		{weekdays, "2024-05-18T12:00:00Z", false}, // Saturday
+	}
+
+	for _, test := range tests {
+		at, _ := time.Parse(time.RFC3339, test.t)
+		if got := test.w.contains(at); got != test.expected {
+			t.Errorf("expected contains(%s) to be %v, got %v", test.t, test.expected, got)
+		}
+	}
+}
+
+func TestScheduleWindowDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	w := ScheduleWindow{Start: 9 * time.Hour, End: 17 * time.Hour, Location: loc}
+
+	// The clocks went forward an hour at 2am, so only 8 hours have passed
+	// since midnight at 9am.
+	if !w.contains(time.Date(2024, 3, 10, 9, 0, 0, 0, loc)) {
+		t.Fatal("expected 9am to be in the window on the day the clocks change")
+	}
+	if w.contains(time.Date(2024, 3, 10, 8, 59, 0, 0, loc)) {
+		t.Fatal("expected 8:59am to be outside the window on the day the clocks change")
+	}
+}
+
+func TestScheduledBreaker(t *testing.T) {
+	// The mock clock starts at midnight UTC.
+	c := clock.NewMock()
+	c.Add(12 * time.Hour)
+
+	cb := NewBreakerWithOptions(&Options{
+		Clock:      c,
+		ShouldTrip: ThresholdTripFunc(3),
+		Schedule: []ScheduleWindow{{
+			Start:      9 * time.Hour,
+			End:        17 * time.Hour,
+			Location:   time.UTC,
+			ShouldTrip: ThresholdTripFunc(1),
+		}},
+	})
+
+	cb.Fail()
+	if !cb.Tripped() {
+		t.Fatal("expected the business hours threshold to trip the breaker")
+	}
+
+	cb.Reset()
+	c.Add(8 * time.Hour)
+	cb.Fail()
+	cb.Fail()
+	if cb.Tripped() {
+		t.Fatal("expected the looser threshold to apply outside business hours")
+	}
+	cb.Fail()
+	if !cb.Tripped() {
+		t.Fatal("expected the default threshold to trip the breaker")
+	}
+}
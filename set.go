@@ -0,0 +1,132 @@
+package circuit
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// BreakerSet holds breakers keyed by an arbitrary label, such as a tenant,
+// shard or queue name, while bounding the number of live breakers. When the
+// set is full, the least recently used breaker that is not tripped is evicted
+// to make room for a new label. If every breaker is tripped, new labels share
+// the set's overflow breaker until room is made.
+//
+// Breakers in a set are not added to a Panel. Evicted and removed breakers
+// are closed, which stops any syncing with a Backend the template has, but
+// they can still be called by those holding on to them.
+type BreakerSet struct {
+	// New creates the breaker for a label. It defaults to cloning the
+	// template passed to NewBreakerSet.
+	New func(label string) *Breaker
+
+	max      int
+	overflow *Breaker
+	entries  map[string]*list.Element
+	lru      *list.List
+	lock     sync.Mutex
+}
+
+type setEntry struct {
+	label string
+	cb    *Breaker
+}
+
+// NewBreakerSet creates a BreakerSet holding at most max breakers, each
+// created with template.CloneConfig(). The overflow breaker is also a clone of
+// template.
+func NewBreakerSet(template *Breaker, max int) *BreakerSet {
+	if max < 1 {
+		max = 1
+	}
+	return &BreakerSet{
+		New: func(string) *Breaker {
+			return template.CloneConfig()
+		},
+		max:      max,
+		overflow: template.CloneConfig(),
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Get returns the breaker for label, creating it if needed. It returns the
+// overflow breaker when the set is full and nothing can be evicted.
+func (s *BreakerSet) Get(label string) *Breaker {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.entries[label]; ok {
+		s.lru.MoveToFront(e)
+		return e.Value.(*setEntry).cb
+	}
+
+	if s.lru.Len() >= s.max && !s.evict() {
+		return s.overflow
+	}
+
+	cb := s.New(label)
+	if cb.Name == "" {
+		cb.Name = label
+	}
+	s.entries[label] = s.lru.PushFront(&setEntry{label: label, cb: cb})
+	return cb
+}
+
+// Call calls circuit using the breaker for label.
+func (s *BreakerSet) Call(label string, circuit func() error, timeout time.Duration) error {
+	return s.Get(label).Call(circuit, timeout)
+}
+
+// Overflow returns the breaker shared by labels that did not fit in the set.
+func (s *BreakerSet) Overflow() *Breaker {
+	return s.overflow
+}
+
+// Remove removes the breaker for label from the set and closes it.
+func (s *BreakerSet) Remove(label string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.entries[label]; ok {
+		s.lru.Remove(e)
+		delete(s.entries, label)
+		e.Value.(*setEntry).cb.Close()
+	}
+}
+
+// Len returns the number of breakers in the set, not counting the overflow
+// breaker.
+func (s *BreakerSet) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lru.Len()
+}
+
+// Labels returns the labels in the set, most recently used first.
+func (s *BreakerSet) Labels() []string {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	labels := make([]string, 0, s.lru.Len())
+	for e := s.lru.Front(); e != nil; e = e.Next() {
+		labels = append(labels, e.Value.(*setEntry).label)
+	}
+	return labels
+}
+
+// evict removes and closes the least recently used breaker that is not
+// tripped. It returns false if every breaker is tripped.
+func (s *BreakerSet) evict() bool {
+	for e := s.lru.Back(); e != nil; e = e.Prev() {
+		entry := e.Value.(*setEntry)
+		if entry.cb.Tripped() {
+			continue
+		}
+		s.lru.Remove(e)
+		delete(s.entries, entry.label)
+		entry.cb.Close()
+		return true
+	}
+	return false
+}
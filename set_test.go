@@ -0,0 +1,66 @@
+package circuit
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBreakerSetEvictsLeastRecentlyUsed(t *testing.T) {
+	s := NewBreakerSet(NewThresholdBreaker(1), 2)
+
+	a := s.Get("a")
+	s.Get("b")
+	if s.Get("a") != a {
+		t.Fatal("expected Get to return the existing breaker")
+	}
+	if a.Name != "a" {
+		t.Fatalf("expected breaker to be named a, got %s", a.Name)
+	}
+
+	s.Get("c")
+	if labels := s.Labels(); !reflect.DeepEqual(labels, []string{"c", "a"}) {
+		t.Fatalf("expected labels [c a], got %v", labels)
+	}
+}
+
+func TestBreakerSetKeepsTrippedBreakers(t *testing.T) {
+	s := NewBreakerSet(NewThresholdBreaker(1), 2)
+
+	s.Get("a").Trip()
+	s.Get("b")
+	s.Get("c")
+	if labels := s.Labels(); !reflect.DeepEqual(labels, []string{"c", "a"}) {
+		t.Fatalf("expected tripped breaker to be kept, got %v", labels)
+	}
+
+	s.Get("c").Trip()
+	if cb := s.Get("d"); cb != s.Overflow() {
+		t.Fatal("expected the overflow breaker when every breaker is tripped")
+	}
+	if s.Len() != 2 {
+		t.Fatalf("expected 2 breakers, got %d", s.Len())
+	}
+
+	s.Remove("a")
+	if cb := s.Get("d"); cb == s.Overflow() {
+		t.Fatal("expected a new breaker after making room")
+	}
+}
+
+func TestBreakerSetClosesEvictedBreakers(t *testing.T) {
+	s := NewBreakerSet(NewThresholdBreaker(1), 1)
+
+	a := s.Get("a")
+	aEvents := a.Subscribe()
+	s.Get("b")
+	if _, ok := <-aEvents; ok {
+		t.Fatal("expected the evicted breaker to be closed")
+	}
+
+	b := s.Get("b")
+	bEvents := b.Subscribe()
+	s.Remove("b")
+	if _, ok := <-bEvents; ok {
+		t.Fatal("expected the removed breaker to be closed")
+	}
+}
@@ -0,0 +1,111 @@
+package circuit
+
+import (
+	"encoding/json"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Snapshot is a point in time view of a breaker's health, see
+// Breaker.Snapshot. Its JSON encoding is the representation used by every
+// HTTP surface, so fields may be added but never renamed or removed.
+type Snapshot struct {
+	Name           string  `json:"name"`
+	State          State   `json:"state"`
+	Broken         bool    `json:"broken"`
+	Failures       int64   `json:"failures"`
+	Successes      int64   `json:"successes"`
+	ConsecFailures int64   `json:"consecutive_failures"`
+	ErrorRate      float64 `json:"error_rate"`
+	MeanLatency    float64 `json:"mean_latency_ms"`
+
+	// Timeouts is the number of Failures that were calls timing out.
+	Timeouts int64 `json:"timeouts"`
+
+	// LastError is the message of the most recent error in the breaker's
+	// error history, if any. See Options.ErrorHistory.
+	LastError string `json:"last_error,omitempty"`
+
+	// LastFailure is the time of the most recent failure or trip, or the zero time
+	// if there has been none.
+	LastFailure time.Time `json:"last_failure"`
+
+	// NextRetry is the time a tripped breaker will next allow a trial call,
+	// or the zero time if it is not tripped or will not retry.
+	NextRetry time.Time `json:"next_retry"`
+
+	// Trips is the number of times the breaker has tripped.
+	Trips int64 `json:"trips"`
+}
+
+// Snapshot returns the breaker's current statistics. It is safe to call
+// concurrently with calls through the breaker, and does not claim a half open
+// trial call.
+func (cb *Breaker) Snapshot() Snapshot {
+	s := Snapshot{
+		Name:           cb.Name,
+		State:          cb.State(),
+		Broken:         atomic.LoadInt32(&cb.broken) == 1,
+		Failures:       cb.Failures(),
+		Successes:      cb.Successes(),
+		ConsecFailures: cb.ConsecFailures(),
+		ErrorRate:      cb.ErrorRate(),
+		MeanLatency:    float64(cb.MeanLatency()) / float64(time.Millisecond),
+		Timeouts:       cb.Timeouts(),
+		Trips:          cb.Trips(),
+	}
+
+	if errs := cb.Errors(); len(errs) > 0 {
+		if err := errs[len(errs)-1].Err; err != nil {
+			s.LastError = err.Error()
+		}
+	}
+	if last := atomic.LoadInt64(&cb.lastFailure); last != 0 {
+		s.LastFailure = time.Unix(0, last)
+	}
+	if cb.Tripped() {
+		if d, ok := cb.retryIn(); ok {
+			s.NextRetry = cb.Clock.Now().Add(d)
+		}
+	}
+	return s
+}
+
+// Snapshots returns a Snapshot of every breaker in the panel, keyed and named
+// by the names they were added as.
+func (p *Panel) Snapshots() map[string]Snapshot {
+	breakers := p.Breakers()
+	snapshots := make(map[string]Snapshot, len(breakers))
+	for name, cb := range breakers {
+		s := cb.Snapshot()
+		s.Name = name
+		snapshots[name] = s
+	}
+	return snapshots
+}
+
+// MarshalJSON encodes the breaker's live statistics. This representation is
+// used by all of the package's HTTP surfaces and is separate from any
+// persistence format.
+func (cb *Breaker) MarshalJSON() ([]byte, error) {
+	return json.Marshal(cb.Snapshot())
+}
+
+// MarshalJSON encodes the live statistics of every breaker in the panel, in
+// the same format as Breaker.MarshalJSON, as a list sorted by name.
+func (p *Panel) MarshalJSON() ([]byte, error) {
+	snapshots := p.Snapshots()
+	breakers := make([]Snapshot, 0, len(snapshots))
+	for _, s := range snapshots {
+		breakers = append(breakers, s)
+	}
+
+	sort.Slice(breakers, func(i, j int) bool {
+		return breakers[i].Name < breakers[j].Name
+	})
+
+	return json.Marshal(struct {
+		Breakers []Snapshot `json:"breakers"`
+	}{breakers})
+}
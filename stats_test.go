@@ -0,0 +1,147 @@
+package circuit
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/facebookgo/clock"
+)
+
+func TestBreakerMarshalJSON(t *testing.T) {
+	cb := NewThresholdBreaker(1)
+	cb.Name = "payments"
+	cb.Success()
+	cb.Fail()
+
+	data, err := json.Marshal(cb)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{
+		"name":                 "payments",
+		"state":                "open",
+		"broken":               false,
+		"failures":             1.0,
+		"successes":            1.0,
+		"consecutive_failures": 1.0,
+		"error_rate":           0.5,
+		"mean_latency_ms":      0.0,
+		"trips":                1.0,
+	}
+	for key, value := range expected {
+		if decoded[key] != value {
+			t.Fatalf("expected %s to be %v, got %s", key, value, data)
+		}
+	}
+}
+
+func TestPanelMarshalJSON(t *testing.T) {
+	p := NewPanel()
+	p.Add("b", NewBreaker())
+	p.Add("a", NewBreaker())
+
+	data, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded struct {
+		Breakers []struct {
+			Name  string `json:"name"`
+			State string `json:"state"`
+		} `json:"breakers"`
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded.Breakers) != 2 || decoded.Breakers[0].Name != "a" || decoded.Breakers[1].State != "closed" {
+		t.Fatalf("unexpected panel JSON: %s", data)
+	}
+}
+
+func TestBreakerSnapshot(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{
+		Clock:      c,
+		BackOff:    &backoff.ConstantBackOff{Interval: time.Minute},
+		ShouldTrip: ThresholdTripFunc(2),
+	})
+	cb.Name = "payments"
+
+	s := cb.Snapshot()
+	if s.State != StateClosed || !s.LastFailure.IsZero() || !s.NextRetry.IsZero() || s.LastError != "" {
+		t.Fatalf("unexpected snapshot of a new breaker: %+v", s)
+	}
+
+	c.Add(time.Hour)
+	cb.Call(func() error { return nil }, 0)
+	cb.Call(func() error { return errors.New("first") }, 0)
+	failedAt := c.Now()
+	c.Add(time.Second)
+	cb.Call(func() error { return errors.New("second") }, 0)
+
+	s = cb.Snapshot()
+	if s.Name != "payments" || s.State != StateOpen || s.Failures != 2 || s.Successes != 1 ||
+		s.ConsecFailures != 2 || s.Trips != 1 {
+		t.Fatalf("unexpected snapshot of a tripped breaker: %+v", s)
+	}
+	if s.LastError != "second" {
+		t.Fatalf("expected last error to be %q, got %q", "second", s.LastError)
+	}
+	if !s.LastFailure.Equal(failedAt.Add(time.Second)) {
+		t.Fatalf("expected last failure at %s, got %s", failedAt.Add(time.Second), s.LastFailure)
+	}
+	if !s.NextRetry.Equal(s.LastFailure.Add(time.Minute)) {
+		t.Fatalf("expected next retry at %s, got %s", s.LastFailure.Add(time.Minute), s.NextRetry)
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"name", "broken", "failures", "successes", "consecutive_failures",
+		"error_rate", "mean_latency_ms", "last_error", "last_failure", "next_retry", "trips"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("expected snapshot JSON to have key %q: %s", key, data)
+		}
+	}
+	if decoded["state"] != "open" {
+		t.Errorf("expected state to encode as %q, got %v", "open", decoded["state"])
+	}
+
+	cb.Break()
+	if s := cb.Snapshot(); !s.Broken || !s.NextRetry.IsZero() {
+		t.Fatalf("expected a broken breaker never to retry: %+v", s)
+	}
+}
+
+func TestPanelSnapshots(t *testing.T) {
+	p := NewPanel()
+	a := NewThresholdBreaker(1)
+	p.Add("a", a)
+	p.Add("b", NewBreaker())
+	a.Fail()
+
+	snapshots := p.Snapshots()
+	if len(snapshots) != 2 {
+		t.Fatalf("expected 2 snapshots, got %d", len(snapshots))
+	}
+	if s := snapshots["a"]; s.Name != "a" || s.State != StateOpen || s.Failures != 1 {
+		t.Fatalf("unexpected snapshot of a: %+v", s)
+	}
+	if s := snapshots["b"]; s.Name != "b" || s.State != StateClosed {
+		t.Fatalf("unexpected snapshot of b: %+v", s)
+	}
+}
@@ -0,0 +1,203 @@
+package circuit
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// DefaultSubscriptionBuffer is the number of events buffered for a subscriber
+// when no other size is given.
+var DefaultSubscriptionBuffer = 100
+
+// subscriber receives a breaker's events. deliver must never block, and must
+// do nothing once close has been called. It returns true if an event was
+// dropped to make room for e.
+type subscriber interface {
+	deliver(e ListenerEvent) bool
+	close()
+}
+
+// eventSource is a Breaker or Panel delivering events to subscribers.
+type eventSource interface {
+	removeSubscriber(s subscriber) bool
+}
+
+// Subscription delivers the events of a breaker, or of every breaker in a
+// Panel, on a buffered channel. Delivery
+// never blocks the breaker: when the buffer is full, the oldest event is
+// dropped and counted in Dropped. Call Unsubscribe, or cancel the context the
+// subscription was created with, once the events are no longer needed.
+type Subscription struct {
+	source  eventSource
+	events  chan ListenerEvent
+	dropped int64
+	closed  bool
+	lock    sync.Mutex
+}
+
+// NewSubscription subscribes to the breaker's events, buffering up to buffer
+// of them; zero uses DefaultSubscriptionBuffer. The subscription ends when
+// ctx is done.
+func (cb *Breaker) NewSubscription(ctx context.Context, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultSubscriptionBuffer
+	}
+
+	s := &Subscription{source: cb, events: make(chan ListenerEvent, buffer)}
+	cb.addSubscriber(s)
+	s.unsubscribeWhenDone(ctx)
+	return s
+}
+
+// unsubscribeWhenDone ends the subscription once ctx is done.
+func (s *Subscription) unsubscribeWhenDone(ctx context.Context) {
+	if done := ctx.Done(); done != nil {
+		go func() {
+			<-done
+			s.Unsubscribe()
+		}()
+	}
+}
+
+// Events returns the channel events are delivered on. It is closed when the
+// subscription ends.
+func (s *Subscription) Events() <-chan ListenerEvent {
+	return s.events
+}
+
+// Dropped returns the number of events dropped because the buffer was full.
+func (s *Subscription) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Unsubscribe ends the subscription and closes its channel. It is safe to
+// call more than once.
+func (s *Subscription) Unsubscribe() {
+	s.source.removeSubscriber(s)
+	s.close()
+}
+
+func (s *Subscription) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+}
+
+func (s *Subscription) deliver(e ListenerEvent) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.closed && sendDropOldest(s.events, e) {
+		atomic.AddInt64(&s.dropped, 1)
+		return true
+	}
+	return false
+}
+
+// eventSubscriber delivers BreakerEvents for Subscribe.
+type eventSubscriber struct {
+	events chan BreakerEvent
+	closed bool
+	lock   sync.Mutex
+}
+
+func (s *eventSubscriber) deliver(e ListenerEvent) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return !s.closed && sendDropOldest(s.events, e.Event)
+}
+
+func (s *eventSubscriber) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+}
+
+// listenerSubscriber delivers ListenerEvents for AddListener. The channel
+// belongs to the caller, so it is never closed.
+type listenerSubscriber chan ListenerEvent
+
+func (s listenerSubscriber) deliver(e ListenerEvent) bool {
+	return sendDropOldest(s, e)
+}
+
+func (s listenerSubscriber) close() {}
+
+// sendDropOldest sends v on ch without blocking, dropping the oldest value in
+// ch to make room if needed. It returns true if a value was dropped.
+func sendDropOldest[T any](ch chan T, v T) (dropped bool) {
+	for {
+		select {
+		case ch <- v:
+			return dropped
+		default:
+		}
+
+		select {
+		case <-ch:
+			dropped = true
+		default:
+		}
+	}
+}
+
+// subscriberList is a list of subscribers that is copied on write, so events
+// can be delivered to it without holding its lock.
+type subscriberList struct {
+	list []subscriber
+	lock sync.RWMutex
+}
+
+func (l *subscriberList) load() []subscriber {
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return l.list
+}
+
+func (l *subscriberList) add(s subscriber) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	list := make([]subscriber, len(l.list), len(l.list)+1)
+	copy(list, l.list)
+	l.list = append(list, s)
+}
+
+func (l *subscriberList) remove(s subscriber) bool {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	for i, existing := range l.list {
+		if existing == s {
+			list := make([]subscriber, 0, len(l.list)-1)
+			list = append(list, l.list[:i]...)
+			l.list = append(list, l.list[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// clear removes and returns all of the subscribers.
+func (l *subscriberList) clear() []subscriber {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	list := l.list
+	l.list = nil
+	return list
+}
+
+func (cb *Breaker) addSubscriber(s subscriber) {
+	cb.subscribers.add(s)
+}
+
+func (cb *Breaker) removeSubscriber(s subscriber) bool {
+	return cb.subscribers.remove(s)
+}
@@ -0,0 +1,121 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSubscriptionDropsOldest(t *testing.T) {
+	cb := NewBreaker()
+	s := cb.NewSubscription(context.Background(), 2)
+
+	cb.Trip()
+	cb.Reset()
+	cb.Fail()
+
+	if d := s.Dropped(); d != 1 {
+		t.Fatalf("expected 1 dropped event, got %d", d)
+	}
+	if e := <-s.Events(); e.Event != BreakerReset || e.CB != cb {
+		t.Fatalf("expected the oldest event to be dropped, got %v", e.Event)
+	}
+	if e := <-s.Events(); e.Event != BreakerFail {
+		t.Fatalf("expected a fail event, got %v", e.Event)
+	}
+}
+
+func TestSubscriptionUnsubscribe(t *testing.T) {
+	cb := NewBreaker()
+	s := cb.NewSubscription(context.Background(), 0)
+	s.Unsubscribe()
+	s.Unsubscribe()
+
+	cb.Trip()
+	if _, ok := <-s.Events(); ok {
+		t.Fatal("expected the events channel to be closed")
+	}
+	if len(cb.subscribers.load()) != 0 {
+		t.Fatal("expected the subscription to be removed from the breaker")
+	}
+}
+
+func TestSubscriptionContext(t *testing.T) {
+	cb := NewBreaker()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := cb.NewSubscription(ctx, 0)
+	cancel()
+
+	select {
+	case _, ok := <-s.Events():
+		if ok {
+			t.Fatal("expected no events")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected canceling the context to end the subscription")
+	}
+}
+
+func TestSubscribeDoesNotBlock(t *testing.T) {
+	cb := NewBreaker()
+	events := cb.Subscribe()
+
+	for i := 0; i < DefaultSubscriptionBuffer+10; i++ {
+		cb.Fail()
+	}
+	if len(events) != DefaultSubscriptionBuffer {
+		t.Fatalf("expected a full buffer of %d events, got %d", DefaultSubscriptionBuffer, len(events))
+	}
+}
+
+func TestBreakerClose(t *testing.T) {
+	cb := NewBreaker()
+	events := cb.Subscribe()
+	s := cb.NewSubscription(context.Background(), 0)
+	listener := make(chan ListenerEvent, 1)
+	cb.AddListener(listener)
+
+	cb.Close()
+	cb.Close()
+	cb.Trip()
+
+	if _, ok := <-events; ok {
+		t.Fatal("expected the Subscribe channel to be closed")
+	}
+	if _, ok := <-s.Events(); ok {
+		t.Fatal("expected the subscription to be closed")
+	}
+	select {
+	case e := <-listener:
+		t.Fatalf("expected the listener to be removed, got %v", e.Event)
+	default:
+	}
+}
+
+func TestSubscriptionEventDetails(t *testing.T) {
+	cb := NewThresholdBreaker(1)
+	s := cb.NewSubscription(context.Background(), 0)
+	boom := errors.New("boom")
+
+	cb.Call(func() error { return boom }, 0)
+
+	if e := <-s.Events(); e.Event != BreakerFail || e.From != StateClosed || e.To != StateClosed || e.Err != boom {
+		t.Fatalf("expected a fail while closed, got %v from %v to %v: %v", e.Event, e.From, e.To, e.Err)
+	}
+	e := <-s.Events()
+	if e.Event != BreakerTripped || e.From != StateClosed || e.To != StateOpen {
+		t.Fatalf("expected a trip from closed to open, got %v from %v to %v", e.Event, e.From, e.To)
+	}
+	if e.Err != boom {
+		t.Fatalf("expected the trip to carry the failed call's error, got %v", e.Err)
+	}
+	if e.Time.IsZero() {
+		t.Fatal("expected the event to be timestamped")
+	}
+
+	cb.Reset()
+	if e := <-s.Events(); e.Event != BreakerReset || e.From != StateOpen || e.To != StateClosed || e.Err != nil {
+		t.Fatalf("expected a reset from open to closed, got %v from %v to %v", e.Event, e.From, e.To)
+	}
+}
@@ -0,0 +1,83 @@
+package circuit
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// Transport is an http.RoundTripper that protects requests with circuit
+// breakers, one per host by default. Unlike HTTPClient it does not replace
+// the http.Client, so it can be combined with other RoundTripper middleware
+// and handed to any library that accepts a custom client:
+//
+//	client := &http.Client{
+//		Transport: circuit.NewTransport(http.DefaultTransport, circuit.NewThresholdBreaker(10), 0),
+//	}
+//
+// Requests rejected by an open breaker fail with ErrBreakerOpen, which the
+// http.Client wraps in a *url.Error; IsOpen still recognizes it.
+type Transport struct {
+	// Base is the RoundTripper used to send requests. If nil,
+	// http.DefaultTransport is used.
+	Base http.RoundTripper
+
+	// KeyFunc returns the name of the breaker for a request. If nil, the
+	// request's host is used.
+	KeyFunc func(*http.Request) string
+
+	// Panel holds the transport's breakers, named by key.
+	Panel *Panel
+
+	// Classifier decides whether a response is a failure for the request's
+	// breaker. If nil, DefaultResponseClassifier is used. The response is
+	// returned either way.
+	Classifier func(*http.Response) error
+
+	timeout  time.Duration
+	template *Breaker
+}
+
+// NewTransport creates a Transport sending requests with base. The breaker for
+// each key is created with template.CloneConfig(); if template is nil,
+// NewBreaker() is used, which never trips on its own. Specifying 0 for timeout
+// will give breakers that do not check for time outs; the request's context
+// is honored either way.
+func NewTransport(base http.RoundTripper, template *Breaker, timeout time.Duration) *Transport {
+	if template == nil {
+		template = NewBreaker()
+	}
+	return &Transport{
+		Base:     base,
+		Panel:    NewPanel(),
+		timeout:  timeout,
+		template: template,
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	breaker := t.Breaker(t.key(req))
+	return doHTTP(req.Context(), breaker, t.Classifier, func(ctx context.Context) (*http.Response, error) {
+		return base.RoundTrip(req.WithContext(ctx))
+	}, t.timeout)
+}
+
+// Breaker returns the breaker for key, creating it if needed.
+func (t *Transport) Breaker(key string) *Breaker {
+	return t.Panel.getOrCreate(key, func(string) *Breaker {
+		return t.template.CloneConfig()
+	})
+}
+
+func (t *Transport) key(req *http.Request) string {
+	if t.KeyFunc != nil {
+		return t.KeyFunc(req)
+	}
+	return req.URL.Host
+}
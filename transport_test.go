@@ -0,0 +1,168 @@
+package circuit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestTransport(t *testing.T) {
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host == "down.example.com" {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})
+	client := &http.Client{Transport: NewTransport(base, NewThresholdBreaker(1), 0)}
+
+	if _, err := client.Get("http://down.example.com/"); err == nil || IsOpen(err) {
+		t.Fatalf("expected the transport error, got %v", err)
+	}
+	if _, err := client.Get("http://down.example.com/"); !IsOpen(err) {
+		t.Fatalf("expected an open breaker error, got %v", err)
+	}
+
+	resp, err := client.Get("http://up.example.com/")
+	if err != nil {
+		t.Fatalf("expected other hosts to be unaffected, got %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestTransportKeyFunc(t *testing.T) {
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("failed")
+	})
+	transport := NewTransport(base, NewThresholdBreaker(2), 0)
+	transport.KeyFunc = func(req *http.Request) string {
+		return req.Header.Get("X-Tenant")
+	}
+
+	for _, tenant := range []string{"a", "a", "b"} {
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		req.Header.Set("X-Tenant", tenant)
+		transport.RoundTrip(req)
+	}
+
+	if !transport.Breaker("a").Tripped() {
+		t.Fatal("expected tenant a's breaker to be tripped")
+	}
+	if transport.Breaker("b").Tripped() {
+		t.Fatal("expected tenant b's breaker to not be tripped")
+	}
+}
+
+func TestTransportClassifier(t *testing.T) {
+	status := http.StatusInternalServerError
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: status, Body: http.NoBody, Request: req}, nil
+	})
+	transport := NewTransport(base, NewThresholdBreaker(2), 0)
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get("http://example.com/")
+	if err != nil {
+		t.Fatalf("expected the 500 response to be returned, got %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected status 500, got %d", resp.StatusCode)
+	}
+
+	cb := transport.Breaker("example.com")
+	if cb.Failures() != 1 {
+		t.Fatalf("expected the 500 response to be a failure, got %d failures", cb.Failures())
+	}
+	var statusErr *StatusError
+	if errs := cb.Errors(); len(errs) != 1 || !errors.As(errs[0].Err, &statusErr) || statusErr.StatusCode != 500 {
+		t.Fatalf("expected a StatusError to be recorded, got %v", errs)
+	}
+
+	status = http.StatusNotFound
+	resp, _ = client.Get("http://example.com/")
+	resp.Body.Close()
+	if cb.Failures() != 1 || cb.Successes() != 1 {
+		t.Fatal("expected the 404 response to be a success")
+	}
+
+	transport.Classifier = func(resp *http.Response) error {
+		if resp.StatusCode == http.StatusNotFound {
+			return errors.New("not found")
+		}
+		return nil
+	}
+	resp, _ = client.Get("http://example.com/")
+	resp.Body.Close()
+	if !cb.Tripped() {
+		t.Fatal("expected the custom classifier to trip the breaker")
+	}
+}
+
+// closeBody records whether it was closed.
+type closeBody struct {
+	closed chan struct{}
+}
+
+func (b *closeBody) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (b *closeBody) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestTransportTimeout(t *testing.T) {
+	body := &closeBody{closed: make(chan struct{})}
+	canceled := make(chan struct{})
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		close(canceled)
+		// A response arriving after the time out must still be closed.
+		return &http.Response{StatusCode: 200, Body: body}, nil
+	})
+	transport := NewTransport(base, NewThresholdBreaker(1), 10*time.Millisecond)
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if _, err := transport.RoundTrip(req); err != ErrBreakerTimeout {
+		t.Fatalf("expected a time out, got %v", err)
+	}
+	for _, done := range []chan struct{}{canceled, body.closed} {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("expected the request to be canceled and its late response closed")
+		}
+	}
+}
+
+func TestTransportBodyContext(t *testing.T) {
+	var reqCtx context.Context
+	base := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		reqCtx = req.Context()
+		return &http.Response{StatusCode: 200, Body: http.NoBody}, nil
+	})
+	transport := NewTransport(base, nil, time.Second)
+
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reqCtx.Err() != nil {
+		t.Fatal("expected the request's context to stay open while the body is read")
+	}
+	resp.Body.Close()
+	if reqCtx.Err() == nil {
+		t.Fatal("expected closing the body to cancel the request's context")
+	}
+}
@@ -0,0 +1,128 @@
+package circuit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// WebhookError is returned by Deliver when a destination responds with a
+// non-2xx status code. It counts as a failure for the destination's breaker.
+type WebhookError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *WebhookError) Error() string {
+	return fmt.Sprintf("webhook %s responded with status %d", e.URL, e.StatusCode)
+}
+
+// WebhookStats holds the statistics of a single webhook destination.
+type WebhookStats struct {
+	Tripped        bool
+	Failures       int64
+	Successes      int64
+	ConsecFailures int64
+	ErrorRate      float64
+}
+
+// WebhookDispatcher delivers webhook payloads over HTTP, maintaining one breaker
+// per destination URL. Deliveries to a destination whose breaker is open are
+// skipped and, if Deferred is set, handed to it so they can be retried later.
+type WebhookDispatcher struct {
+	Client *http.Client
+	Panel  *Panel
+
+	// Deferred is called with deliveries that were skipped because the
+	// destination's breaker is open.
+	Deferred func(url, contentType string, payload []byte)
+
+	timeout   time.Duration
+	threshold int64
+}
+
+// NewWebhookDispatcher creates a WebhookDispatcher. Each destination gets a
+// threshold breaker that trips after threshold failures. Specifying 0 for
+// timeout will give breakers that do not check for time outs.
+func NewWebhookDispatcher(timeout time.Duration, threshold int64, client *http.Client) *WebhookDispatcher {
+	if client == nil {
+		client = &http.Client{}
+	}
+
+	return &WebhookDispatcher{
+		Client:    client,
+		Panel:     NewPanel(),
+		timeout:   timeout,
+		threshold: threshold,
+	}
+}
+
+// Deliver POSTs payload to url. Transport errors and non-2xx responses are
+// recorded as failures. If the destination's breaker is open, the payload is
+// passed to Deferred and ErrBreakerOpen is returned.
+func (d *WebhookDispatcher) Deliver(url, contentType string, payload []byte) error {
+	breaker := d.Breaker(url)
+	err := breaker.Call(func() error {
+		resp, err := d.Client.Post(url, contentType, bytes.NewReader(payload))
+		if err != nil {
+			return err
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return &WebhookError{URL: url, StatusCode: resp.StatusCode}
+		}
+		return nil
+	}, d.timeout)
+
+	if err == ErrBreakerOpen && d.Deferred != nil {
+		d.Deferred(url, contentType, payload)
+	}
+	return err
+}
+
+// Breaker returns the breaker for the destination url, creating it if needed.
+func (d *WebhookDispatcher) Breaker(url string) *Breaker {
+	return d.Panel.getOrCreate(url, func(string) *Breaker {
+		return NewThresholdBreaker(d.threshold)
+	})
+}
+
+// Stats returns the statistics for the destination url. ok is false if
+// nothing has been delivered to url.
+func (d *WebhookDispatcher) Stats(url string) (stats WebhookStats, ok bool) {
+	d.Panel.panelLock.RLock()
+	cb, ok := d.Panel.Circuits[url]
+	d.Panel.panelLock.RUnlock()
+
+	if !ok {
+		return stats, false
+	}
+	return webhookStats(cb), true
+}
+
+// AllStats returns the statistics of every known destination, keyed by URL.
+func (d *WebhookDispatcher) AllStats() map[string]WebhookStats {
+	d.Panel.panelLock.RLock()
+	defer d.Panel.panelLock.RUnlock()
+
+	stats := make(map[string]WebhookStats, len(d.Panel.Circuits))
+	for url, cb := range d.Panel.Circuits {
+		stats[url] = webhookStats(cb)
+	}
+	return stats
+}
+
+func webhookStats(cb *Breaker) WebhookStats {
+	return WebhookStats{
+		Tripped:        cb.Tripped(),
+		Failures:       cb.Failures(),
+		Successes:      cb.Successes(),
+		ConsecFailures: cb.ConsecFailures(),
+		ErrorRate:      cb.ErrorRate(),
+	}
+}
@@ -0,0 +1,48 @@
+package circuit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookDispatcher(t *testing.T) {
+	good := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer good.Close()
+	bad := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer bad.Close()
+
+	var deferred []string
+	d := NewWebhookDispatcher(0, 2, nil)
+	d.Deferred = func(url, contentType string, payload []byte) {
+		deferred = append(deferred, string(payload))
+	}
+
+	for i := 0; i < 2; i++ {
+		err := d.Deliver(bad.URL, "application/json", []byte("{}"))
+		if we, ok := err.(*WebhookError); !ok || we.StatusCode != 500 {
+			t.Fatalf("expected a WebhookError with status 500, got %v", err)
+		}
+	}
+
+	if err := d.Deliver(bad.URL, "application/json", []byte("deferred")); err != ErrBreakerOpen {
+		t.Fatalf("expected delivery to be skipped, got %v", err)
+	}
+	if len(deferred) != 1 || deferred[0] != "deferred" {
+		t.Fatalf("expected skipped delivery to be deferred, got %v", deferred)
+	}
+
+	if err := d.Deliver(good.URL, "application/json", []byte("{}")); err != nil {
+		t.Fatalf("expected delivery to succeed, got %v", err)
+	}
+
+	stats, ok := d.Stats(bad.URL)
+	if !ok || !stats.Tripped || stats.Failures != 2 {
+		t.Fatalf("expected bad destination to be tripped with 2 failures, got %+v", stats)
+	}
+	if all := d.AllStats(); len(all) != 2 || all[good.URL].Successes != 1 {
+		t.Fatalf("expected stats for 2 destinations, got %+v", all)
+	}
+}
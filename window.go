@@ -1,11 +1,9 @@
 package circuit
 
 import (
-	"container/ring"
+	"math"
 	"sync"
 	"time"
-
-	"github.com/facebookgo/clock"
 )
 
 var (
@@ -16,16 +14,65 @@ var (
 	DefaultWindowBuckets = 10
 )
 
-// bucket holds counts of failures and successes
+// Latency histogram bins. Bin i counts durations up to latencyBound(i), which
+// grows by latencyGrowth per bin from latencyBase; the last bin counts all
+// longer durations.
+const (
+	latencyBins   = 256
+	latencyBase   = time.Microsecond
+	latencyGrowth = 1.1
+)
+
+// bucket holds counts of failures and successes, and the total duration and
+// a histogram of the outcomes that were recorded with one, for the epoch it
+// covers.
 type bucket struct {
-	failure int64
-	success int64
+	epoch    int64
+	failure  int64
+	timeout  int64
+	success  int64
+	timed    int64
+	duration time.Duration
+	latency  *[latencyBins]int64
 }
 
 // Reset resets the counts to 0
 func (b *bucket) Reset() {
 	b.failure = 0
+	b.timeout = 0
 	b.success = 0
+	b.timed = 0
+	b.duration = 0
+	if b.latency != nil {
+		*b.latency = [latencyBins]int64{}
+	}
+}
+
+// Time records the duration of an outcome
+func (b *bucket) Time(d time.Duration) {
+	b.timed++
+	b.duration += d
+	if b.latency == nil {
+		b.latency = new([latencyBins]int64)
+	}
+	b.latency[latencyBin(d)]++
+}
+
+// latencyBin returns the histogram bin counting d.
+func latencyBin(d time.Duration) int {
+	if d <= latencyBase {
+		return 0
+	}
+	i := int(math.Ceil(math.Log(float64(d)/float64(latencyBase)) / math.Log(latencyGrowth)))
+	if i >= latencyBins {
+		return latencyBins - 1
+	}
+	return i
+}
+
+// latencyBound returns the longest duration counted by bin i.
+func latencyBound(i int) time.Duration {
+	return time.Duration(float64(latencyBase) * math.Pow(latencyGrowth, float64(i)))
 }
 
 // Fail increments the failure count
@@ -38,38 +85,48 @@ func (b *bucket) Success() {
 	b.success++
 }
 
-// window maintains a ring of buckets and increments the failure and success
-// counts of the current bucket. Once a specified time has elapsed, it will
-// advance to the next bucket, reseting its counts. This allows the keeping of
-// rolling statistics on the counts.
+// window divides a span of time into buckets and records failures and
+// successes in the bucket covering the current time, keeping rolling
+// statistics on the counts.
+//
+// Each bucket covers one epoch, the current time divided by the bucket time,
+// and is reused for a later epoch once the window has moved past it. Buckets
+// are advanced lazily: a write to a bucket holding an older epoch resets it
+// first, and reads skip buckets whose epoch has left the window. No goroutine
+// or timer is needed to move the window along.
 type window struct {
-	buckets    *ring.Ring
+	buckets    []bucket
 	bucketTime time.Duration
 	bucketLock sync.RWMutex
-	lastAccess time.Time
-	clock      clock.Clock
+	now        func() time.Time
 }
 
 // newWindow creates a new window. windowTime is the time covering the entire
 // window. windowBuckets is the number of buckets the window is divided into.
 // An example: a 10 second window with 10 buckets will have 10 buckets covering
-// 1 second each.
-func newWindow(windowTime time.Duration, windowBuckets int) *window {
-	buckets := ring.New(windowBuckets)
-	for i := 0; i < buckets.Len(); i++ {
-		buckets.Value = &bucket{}
-		buckets = buckets.Next()
+// 1 second each. now returns the current time; if it is nil, time.Now is used.
+func newWindow(windowTime time.Duration, windowBuckets int, now func() time.Time) *window {
+	if now == nil {
+		now = time.Now
 	}
-
-	clock := clock.New()
-
-	bucketTime := time.Duration(windowTime.Nanoseconds() / int64(windowBuckets))
 	return &window{
-		buckets:    buckets,
-		bucketTime: bucketTime,
-		clock:      clock,
-		lastAccess: clock.Now(),
+		buckets:    make([]bucket, windowBuckets),
+		bucketTime: bucketTime(windowTime, windowBuckets),
+		now:        now,
+	}
+}
+
+// bucketTime returns the time each of windowBuckets buckets covers.
+func bucketTime(windowTime time.Duration, windowBuckets int) time.Duration {
+	if d := windowTime / time.Duration(windowBuckets); d > 0 {
+		return d
 	}
+	return 1
+}
+
+// epoch returns the epoch of the current time.
+func (w *window) epoch() int64 {
+	return w.now().UnixNano() / int64(w.bucketTime)
 }
 
 // Fail records a failure in the current bucket.
@@ -88,27 +145,65 @@ func (w *window) Success() {
 	w.bucketLock.Unlock()
 }
 
+// FailWithDuration records a failure that took d in the current bucket.
+func (w *window) FailWithDuration(d time.Duration) {
+	w.bucketLock.Lock()
+	b := w.getLatestBucket()
+	b.Fail()
+	b.Time(d)
+	w.bucketLock.Unlock()
+}
+
+// TimeoutWithDuration records a failure that timed out after d in the current
+// bucket.
+func (w *window) TimeoutWithDuration(d time.Duration) {
+	w.bucketLock.Lock()
+	b := w.getLatestBucket()
+	b.Fail()
+	b.timeout++
+	b.Time(d)
+	w.bucketLock.Unlock()
+}
+
+// SuccessWithDuration records a success that took d in the current bucket.
+func (w *window) SuccessWithDuration(d time.Duration) {
+	w.bucketLock.Lock()
+	b := w.getLatestBucket()
+	b.Success()
+	b.Time(d)
+	w.bucketLock.Unlock()
+}
+
 // Failures returns the total number of failures recorded in all buckets.
 func (w *window) Failures() int64 {
-	w.bucketLock.RLock()
-
 	var failures int64
-	w.buckets.Do(func(x interface{}) {
-		b := x.(*bucket)
+
+	w.bucketLock.RLock()
+	w.each(func(b *bucket) {
 		failures += b.failure
 	})
-
 	w.bucketLock.RUnlock()
 	return failures
 }
 
-// Successes returns the total number of successes recorded in all buckets.
-func (w *window) Successes() int64 {
+// Timeouts returns the total number of timeouts recorded in all buckets.
+func (w *window) Timeouts() int64 {
+	var timeouts int64
+
 	w.bucketLock.RLock()
+	w.each(func(b *bucket) {
+		timeouts += b.timeout
+	})
+	w.bucketLock.RUnlock()
+	return timeouts
+}
 
+// Successes returns the total number of successes recorded in all buckets.
+func (w *window) Successes() int64 {
 	var successes int64
-	w.buckets.Do(func(x interface{}) {
-		b := x.(*bucket)
+
+	w.bucketLock.RLock()
+	w.each(func(b *bucket) {
 		successes += b.success
 	})
 	w.bucketLock.RUnlock()
@@ -122,8 +217,7 @@ func (w *window) ErrorRate() float64 {
 	var failures int64
 
 	w.bucketLock.RLock()
-	w.buckets.Do(func(x interface{}) {
-		b := x.(*bucket)
+	w.each(func(b *bucket) {
 		total += b.failure + b.success
 		failures += b.failure
 	})
@@ -136,39 +230,129 @@ func (w *window) ErrorRate() float64 {
 	return float64(failures) / float64(total)
 }
 
-// Reset resets the count of all buckets.
-func (w *window) Reset() {
+// MeanLatency returns the mean duration of the outcomes recorded with a
+// duration in all buckets, or 0 if there are none.
+func (w *window) MeanLatency() time.Duration {
+	var timed int64
+	var duration time.Duration
+
+	w.bucketLock.RLock()
+	w.each(func(b *bucket) {
+		timed += b.timed
+		duration += b.duration
+	})
+	w.bucketLock.RUnlock()
+
+	if timed == 0 {
+		return 0
+	}
+
+	return duration / time.Duration(timed)
+}
+
+// LatencyQuantile estimates the q-quantile (e.g. 0.99) of the durations
+// recorded in all buckets, interpolating within the histogram bins. It also
+// returns the number of durations recorded; the estimate is 0 if there are
+// none.
+func (w *window) LatencyQuantile(q float64) (time.Duration, int64) {
+	var counts [latencyBins]int64
+	var timed int64
+
+	w.bucketLock.RLock()
+	w.each(func(b *bucket) {
+		if b.latency == nil {
+			return
+		}
+		timed += b.timed
+		for i, n := range b.latency {
+			counts[i] += n
+		}
+	})
+	w.bucketLock.RUnlock()
+
+	if timed == 0 {
+		return 0, 0
+	}
+
+	rank := q * float64(timed)
+	var seen int64
+	for i, n := range counts {
+		if n == 0 || float64(seen+n) < rank {
+			seen += n
+			continue
+		}
+		lower := time.Duration(0)
+		if i > 0 {
+			lower = latencyBound(i - 1)
+		}
+		upper := latencyBound(i)
+		fraction := (rank - float64(seen)) / float64(n)
+		return lower + time.Duration(fraction*float64(upper-lower)), timed
+	}
+	return latencyBound(latencyBins - 1), timed
+}
+
+// Resize changes the time the window covers and the number of buckets it is
+// divided into. The totals of all counts are kept in the current bucket.
+func (w *window) Resize(windowTime time.Duration, windowBuckets int) {
 	w.bucketLock.Lock()
+	defer w.bucketLock.Unlock()
 
-	w.buckets.Do(func(x interface{}) {
-		x.(*bucket).Reset()
+	var total bucket
+	w.each(func(b *bucket) {
+		total.failure += b.failure
+		total.timeout += b.timeout
+		total.success += b.success
+		total.timed += b.timed
+		total.duration += b.duration
+		if b.latency != nil {
+			if total.latency == nil {
+				total.latency = new([latencyBins]int64)
+			}
+			for i, n := range b.latency {
+				total.latency[i] += n
+			}
+		}
 	})
+
+	w.buckets = make([]bucket, windowBuckets)
+	w.bucketTime = bucketTime(windowTime, windowBuckets)
+	b := w.getLatestBucket()
+	total.epoch = b.epoch
+	*b = total
+}
+
+// Reset resets the count of all buckets.
+func (w *window) Reset() {
+	w.bucketLock.Lock()
+	for i := range w.buckets {
+		w.buckets[i].Reset()
+	}
 	w.bucketLock.Unlock()
 }
 
-// getLatestBucket returns the current bucket. If the bucket time has elapsed
-// it will move to the next bucket, resetting its counts and updating the last
-// access time before returning it. getLatestBucket assumes that the caller has
-// locked the bucketLock
-func (w *window) getLatestBucket() *bucket {
-	var b *bucket
-	b = w.buckets.Value.(*bucket)
-	elapsed := w.clock.Now().Sub(w.lastAccess)
-
-	if elapsed > w.bucketTime {
-		// Reset the buckets between now and number of buckets ago. If
-		// that is more that the existing buckets, reset all.
-		for i := 0; i < w.buckets.Len(); i++ {
-			w.buckets = w.buckets.Next()
-			b = w.buckets.Value.(*bucket)
-			b.Reset()
-			elapsed = time.Duration(int64(elapsed) - int64(w.bucketTime))
-			if elapsed < w.bucketTime {
-				// Done resetting buckets.
-				break
-			}
+// each calls fn with every bucket whose epoch is still within the window.
+// each assumes that the caller has locked the bucketLock.
+func (w *window) each(fn func(b *bucket)) {
+	epoch := w.epoch()
+	n := int64(len(w.buckets))
+	for i := range w.buckets {
+		b := &w.buckets[i]
+		if b.epoch <= epoch && epoch-b.epoch < n {
+			fn(b)
 		}
-		w.lastAccess = w.clock.Now()
+	}
+}
+
+// getLatestBucket returns the bucket for the current epoch. If the bucket
+// still holds the counts of an earlier epoch they are reset first.
+// getLatestBucket assumes that the caller has locked the bucketLock.
+func (w *window) getLatestBucket() *bucket {
+	epoch := w.epoch()
+	b := &w.buckets[epoch%int64(len(w.buckets))]
+	if b.epoch != epoch {
+		b.Reset()
+		b.epoch = epoch
 	}
 	return b
 }
@@ -1,6 +1,7 @@
 package circuit
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -8,7 +9,7 @@ import (
 )
 
 func TestWindowCounts(t *testing.T) {
-	w := newWindow(time.Millisecond*10, 2)
+	w := newWindow(time.Millisecond*10, 2, nil)
 	w.Fail()
 	w.Fail()
 	w.Success()
@@ -38,17 +39,14 @@ func TestWindowCounts(t *testing.T) {
 func TestWindowSlides(t *testing.T) {
 	c := clock.NewMock()
 
-	w := newWindow(time.Millisecond*10, 2)
-	w.clock = c
-	w.lastAccess = c.Now()
+	w := newWindow(time.Millisecond*10, 2, c.Now)
 
 	w.Fail()
 	c.Add(time.Millisecond * 6)
 	w.Fail()
 
 	counts := 0
-	w.buckets.Do(func(x interface{}) {
-		b := x.(*bucket)
+	w.each(func(b *bucket) {
 		if b.failure > 0 {
 			counts++
 		}
@@ -61,8 +59,7 @@ func TestWindowSlides(t *testing.T) {
 	c.Add(time.Millisecond * 15)
 	w.Success()
 	counts = 0
-	w.buckets.Do(func(x interface{}) {
-		b := x.(*bucket)
+	w.each(func(b *bucket) {
 		if b.failure > 0 {
 			counts++
 		}
@@ -72,3 +69,120 @@ func TestWindowSlides(t *testing.T) {
 		t.Fatalf("expected 0 buckets to have failures, got %d", counts)
 	}
 }
+
+func TestWindowExpiresOnRead(t *testing.T) {
+	c := clock.NewMock()
+	w := newWindow(time.Second, 10, c.Now)
+
+	w.Fail()
+	c.Add(500 * time.Millisecond)
+	w.Success()
+
+	c.Add(450 * time.Millisecond)
+	if f, s := w.Failures(), w.Successes(); f != 1 || s != 1 {
+		t.Fatalf("expected 1 failure and 1 success within the window, got %d and %d", f, s)
+	}
+
+	c.Add(50 * time.Millisecond)
+	if f, s := w.Failures(), w.Successes(); f != 0 || s != 1 {
+		t.Fatalf("expected the failure to leave the window, got %d failures and %d successes", f, s)
+	}
+	if r := w.ErrorRate(); r != 0 {
+		t.Fatalf("expected an error rate of 0, got %f", r)
+	}
+
+	c.Add(time.Hour)
+	if s := w.Successes(); s != 0 {
+		t.Fatalf("expected an idle window to be empty, got %d successes", s)
+	}
+}
+
+func TestWindowResize(t *testing.T) {
+	c := clock.NewMock()
+	w := newWindow(time.Second, 10, c.Now)
+
+	w.Fail()
+	c.Add(500 * time.Millisecond)
+	w.SuccessWithDuration(time.Millisecond)
+
+	w.Resize(10*time.Second, 5)
+	if f, s := w.Failures(), w.Successes(); f != 1 || s != 1 {
+		t.Fatalf("expected resizing to keep the counts, got %d failures and %d successes", f, s)
+	}
+	if l := w.MeanLatency(); l != time.Millisecond {
+		t.Fatalf("expected resizing to keep the latency, got %v", l)
+	}
+
+	c.Add(9 * time.Second)
+	w.Fail()
+	if f := w.Failures(); f != 2 {
+		t.Fatalf("expected 2 failures in the resized window, got %d", f)
+	}
+	c.Add(2 * time.Second)
+	if f := w.Failures(); f != 1 {
+		t.Fatalf("expected the kept counts to leave the resized window, got %d failures", f)
+	}
+}
+
+func TestBreakerWindowUsesClock(t *testing.T) {
+	c := clock.NewMock()
+	cb := NewBreakerWithOptions(&Options{Clock: c, WindowTime: time.Second, WindowBuckets: 10})
+
+	cb.Fail()
+	if f := cb.Failures(); f != 1 {
+		t.Fatalf("expected 1 failure, got %d", f)
+	}
+
+	c.Add(time.Second)
+	if f := cb.Failures(); f != 0 {
+		t.Fatalf("expected the failure to leave the window on the breaker's clock, got %d", f)
+	}
+}
+
+func TestWindowMeanLatency(t *testing.T) {
+	w := newWindow(time.Millisecond*10, 2, nil)
+	if l := w.MeanLatency(); l != 0 {
+		t.Fatalf("expected empty window to have 0 mean latency, got %v", l)
+	}
+
+	w.SuccessWithDuration(time.Millisecond)
+	w.FailWithDuration(3 * time.Millisecond)
+	w.Success()
+
+	if l := w.MeanLatency(); l != 2*time.Millisecond {
+		t.Fatalf("expected mean latency to be 2ms, got %v", l)
+	}
+	if s := w.Successes(); s != 2 {
+		t.Fatalf("expected window to have 2 successes, got %d", s)
+	}
+}
+
+func TestWindowLatencyQuantile(t *testing.T) {
+	w := newWindow(time.Second, 2, nil)
+	if q, n := w.LatencyQuantile(0.99); q != 0 || n != 0 {
+		t.Fatalf("expected empty window to have no latency, got %v from %d", q, n)
+	}
+
+	for i := 1; i <= 100; i++ {
+		w.SuccessWithDuration(time.Duration(i) * time.Millisecond)
+	}
+	w.Success()
+
+	tests := []struct {
+		q        float64
+		expected time.Duration
+	}{
+		{0.5, 50 * time.Millisecond},
+		{0.9, 90 * time.Millisecond},
+		{0.99, 99 * time.Millisecond},
+	}
+	for _, test := range tests {
+		q, n := w.LatencyQuantile(test.q)
+		if n != 100 {
+			t.Fatalf("expected 100 durations, got %d", n)
+		}
+		if diff := math.Abs(float64(q-test.expected)) / float64(test.expected); diff > 0.1 {
+			t.Errorf("expected %v quantile to be about %v, got %v", test.q, test.expected, q)
+		}
+	}
+}